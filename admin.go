@@ -0,0 +1,174 @@
+package main
+
+import (
+    "log"
+    "sort"
+    "sync"
+    "time"
+    "strings"
+    "net/http"
+    "crypto/subtle"
+    "encoding/json"
+
+    "github.com/spf13/viper"
+)
+
+type CacheEntry struct {
+    Key             string      `json:"key"`
+    CachedAt        time.Time   `json:"cached_at"`
+    Size            int         `json:"size"`
+}
+
+type CacheEntriesResponse struct {
+    Entries         []CacheEntry `json:"entries"`
+    RequestedAt     time.Time   `json:"requested_at"`
+}
+
+type CacheDeleteResponse struct {
+    Deleted         []string    `json:"deleted"`
+}
+
+// warmer warms the cache on demand when the synchronizer is disabled, so warm-ups do not overlap either.
+var (
+    warmer          *Syncer
+    warmerOnce      sync.Once
+)
+
+// adminOnly restricts a handler to callers sending the admin token.
+func adminOnly(h http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+
+        token := viper.GetString("admin.token")
+
+        // Without a token, anyone could manage the cache.
+        if token == "" {
+            writeError(w, r, &ErrorResponse{
+                StatusCode: http.StatusNotFound,
+                Message: "The admin API is not configured",
+            })
+            return
+        }
+
+        if subtle.ConstantTimeCompare([]byte(callerToken(r.Header.Get("Authorization"))), []byte(token)) != 1 {
+            writeError(w, r, &ErrorResponse{
+                StatusCode: http.StatusUnauthorized,
+                Message: "Bad credentials",
+            })
+            return
+        }
+
+        h(w, r)
+    }
+}
+
+// getCache lists the cached entries, with when they were cached and their encoded size.
+func getCache(w http.ResponseWriter, r *http.Request) {
+    res := CacheEntriesResponse{
+        Entries: []CacheEntry{},
+    }
+
+    rangeCache(cache, func(key string, entry []byte) {
+        cachedAt, _ := entryCachedAt(entry)
+
+        res.Entries = append(res.Entries, CacheEntry{
+            Key: key,
+            CachedAt: cachedAt,
+            Size: len(entry),
+        })
+    })
+
+    sort.Slice(res.Entries, func(i, j int) bool {
+        return res.Entries[i].Key < res.Entries[j].Key
+    })
+
+    res.RequestedAt = time.Now()
+    json.NewEncoder(w).Encode(res)
+}
+
+// deleteCacheKey deletes a key in the scope of every caller, or every key starting with a prefix ending in "*".
+func deleteCacheKey(w http.ResponseWriter, r *http.Request) {
+    key := strings.TrimPrefix(r.URL.Path, "/api/admin/cache/")
+    res := CacheDeleteResponse{
+        Deleted: []string{},
+    }
+
+    if prefix := strings.TrimSuffix(key, "*"); prefix != key {
+        res.Deleted = deleteCacheKeys(func(k string) bool {
+            return strings.HasPrefix(k, prefix) || strings.HasPrefix(unscopedKey(k), prefix)
+        })
+    } else if invalidate(key) {
+        res.Deleted = append(res.Deleted, key)
+    } else {
+        writeError(w, r, notFound())
+        return
+    }
+
+    json.NewEncoder(w).Encode(res)
+}
+
+// deleteCache purges every cached entry.
+func deleteCache(w http.ResponseWriter, r *http.Request) {
+    json.NewEncoder(w).Encode(CacheDeleteResponse{
+        Deleted: deleteCacheKeys(func(string) bool { return true }),
+    })
+}
+
+// postCacheWarm fills the cache with the whole catalog in the background, the way the synchronizer does.
+// Only one warm-up or synchronization runs at a time.
+func postCacheWarm(w http.ResponseWriter, r *http.Request) {
+    s := warmingSyncer()
+
+    if !s.begin() {
+        writeError(w, r, &ErrorResponse{
+            StatusCode: http.StatusConflict,
+            Message: "The cache is already being synchronized",
+        })
+        return
+    }
+
+    go func() {
+        if err := s.end(s.sync(ctx)); err != nil {
+            log.Printf("Warming the cache failed: %v", err)
+        }
+    }()
+
+    w.WriteHeader(http.StatusAccepted)
+    json.NewEncoder(w).Encode(struct { Message string } {
+        Message: "Warming the cache",
+    })
+}
+
+// warmingSyncer returns the synchronizer if it is enabled, or else the one warming the cache on demand.
+func warmingSyncer() *Syncer {
+    if syncer != nil {
+        return syncer
+    }
+
+    warmerOnce.Do(func() {
+        warmer = newSyncer()
+    })
+
+    return warmer
+}
+
+// deleteCacheKeys deletes the keys matching a predicate, returning them sorted.
+func deleteCacheKeys(match func(string) bool) []string {
+    var keys []string
+    rangeCache(cache, func(key string, _ []byte) {
+        if match(key) {
+            keys = append(keys, key)
+        }
+    })
+
+    deleted := []string{}
+    for _, key := range keys {
+        if cache.Delete(key) == nil {
+            deleted = append(deleted, key)
+        }
+    }
+
+    sort.Strings(deleted)
+
+    return deleted
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+    "io"
+    "fmt"
+    "time"
+    "testing"
+    "sync/atomic"
+    "net/http"
+    "encoding/json"
+    "net/http/httptest"
+
+    "github.com/spf13/viper"
+)
+
+// -- TESTS --
+
+func TestDeleteCacheKey(t *testing.T) {
+    var deleteCacheKeyTestCases = []adminTestCase{
+        {
+            testName:   "A key should be deleted for every caller",
+            handler:    deleteCacheKey,
+            path:       "/api/admin/cache/language-go",
+            token:      "Bearer admin",
+            status:     http.StatusOK,
+            expected:   []string{"language-go"},
+        },
+        {
+            testName:   "A prefix should delete every key starting with it",
+            handler:    deleteCacheKey,
+            path:       "/api/admin/cache/language-*",
+            token:      "Bearer admin",
+            status:     http.StatusOK,
+            expected:   []string{"language-c", "language-go", "token:caller/language-go"},
+        },
+        {
+            testName:   "A key which is not cached should not be found",
+            handler:    deleteCacheKey,
+            path:       "/api/admin/cache/language-ruby",
+            token:      "Bearer admin",
+            status:     http.StatusNotFound,
+            expected:   nil,
+        },
+        {
+            testName:   "The whole cache should be purged",
+            handler:    deleteCache,
+            path:       "/api/admin/cache",
+            token:      "Bearer admin",
+            status:     http.StatusOK,
+            expected:   []string{"language-c", "language-go", "languages", "token:caller/language-go"},
+        },
+        {
+            testName:   "A caller without the admin token should be refused",
+            handler:    deleteCache,
+            path:       "/api/admin/cache",
+            token:      "Bearer caller",
+            status:     http.StatusUnauthorized,
+            expected:   nil,
+        },
+    }
+
+    defer viper.Set("admin.token", viper.Get("admin.token"))
+    viper.Set("admin.token", "admin")
+
+    for _, c := range deleteCacheKeyTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            for _, key := range []string{"languages", "language-go", "language-c", "token:caller/language-go"} {
+                cacheSet(key, LanguageResponse{CachedAt: time.Now()})
+            }
+
+            assertAdminDelete(t, c.handler, c.path, c.token, c.status, c.expected)
+        })
+    }
+}
+
+func TestGetCache(t *testing.T) {
+    defer viper.Set("admin.token", viper.Get("admin.token"))
+
+    cachedAt := time.Now().Add(-time.Hour).Round(0)
+
+    cache.Reset()
+    cacheSet(languageKey("go"), LanguageResponse{CachedAt: cachedAt})
+
+    t.Run("The admin API should be disabled without a token", func(t *testing.T) {
+        viper.Set("admin.token", "")
+        assertAdminStatus(t, adminOnly(getCache), "", http.StatusNotFound)
+    })
+
+    t.Run("Cached keys should be listed with when they were cached", func(t *testing.T) {
+        viper.Set("admin.token", "admin")
+
+        req := httptest.NewRequest("GET", "http://localhost:8080/api/admin/cache", nil)
+        req.Header.Set("Authorization", "Bearer admin")
+        w := httptest.NewRecorder()
+        adminOnly(getCache)(w, req)
+
+        raw := &CacheEntriesResponse{}
+        if err := json.NewDecoder(w.Result().Body).Decode(raw); err != nil || len(raw.Entries) != 1 {
+            t.Errorf("Entries (%+v) expected to be listed", raw.Entries)
+            return
+        }
+
+        if e := raw.Entries[0]; e.Key != "language-go" || !e.CachedAt.Equal(cachedAt) || e.Size == 0 {
+            t.Errorf("Entry (%+v) expected to be (%v) cached at (%v)", e, "language-go", cachedAt)
+        }
+    })
+}
+
+func TestPostCacheWarm(t *testing.T) {
+    defer viper.Set("admin.token", viper.Get("admin.token"))
+    viper.Set("admin.token", "admin")
+
+    // The code is slow enough to be fetched that the second warm-up starts while the first one runs.
+    var calls int32
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(string) Source {
+        return &slowSource{Source: &fakeSource{"fake": "print('Hello World')"}, calls: &calls}
+    }
+
+    cache.Reset()
+    assertAdminStatus(t, adminOnly(postCacheWarm), "Bearer admin", http.StatusAccepted)
+    assertAdminStatus(t, adminOnly(postCacheWarm), "Bearer admin", http.StatusConflict)
+
+    for i := 0; i < 100 && warmingSyncer().Status().Running; i++ {
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    var res LanguageResponse
+    if err := cacheGet(languageKey("fake"), &res); err != nil {
+        t.Errorf("Cache expected to be warmed with the catalog, but was not (%v)", err)
+    }
+
+    if n := atomic.LoadInt32(&calls); n != 1 {
+        t.Errorf("Code fetched (%d times) expected to be fetched once", n)
+    }
+}
+
+// --- ASSERTS ---
+
+func assertAdminDelete(t *testing.T, fn handler, path string, token string, status int, expected []string) {
+    req := httptest.NewRequest("DELETE", "http://localhost:8080" + path, nil)
+    req.Header.Set("Authorization", token)
+    w := httptest.NewRecorder()
+    adminOnly(http.HandlerFunc(fn))(w, req)
+
+    resp := w.Result()
+    body, _ := io.ReadAll(resp.Body)
+
+    if resp.StatusCode != status {
+        t.Errorf("Status code (%d) expected to be %d", resp.StatusCode, status)
+        return
+    }
+
+    if expected == nil {
+        if cache.Len() != 4 {
+            t.Errorf("No cache entry expected to be deleted")
+        }
+        return
+    }
+
+    raw := &CacheDeleteResponse{}
+    if err := json.Unmarshal(body, &raw); err != nil {
+        t.Errorf("Response body (%s) expected to be marshalled into struct (%#v)", string(body), raw)
+        return
+    }
+
+    if fmt.Sprint(raw.Deleted) != fmt.Sprint(expected) {
+        t.Errorf("Deleted keys (%v) expected to be (%v)", raw.Deleted, expected)
+    }
+
+    for _, key := range expected {
+        if _, err := cache.Get(key); err == nil {
+            t.Errorf("Cache entry (%v) expected to be deleted", key)
+        }
+    }
+}
+
+func assertAdminStatus(t *testing.T, fn http.HandlerFunc, token string, status int) {
+    req := httptest.NewRequest("POST", "http://localhost:8080/api/admin/cache", nil)
+    req.Header.Set("Authorization", token)
+    w := httptest.NewRecorder()
+    fn(w, req)
+
+    if w.Result().StatusCode != status {
+        t.Errorf("Status code (%d) expected to be %d", w.Result().StatusCode, status)
+    }
+}
+
+// --- STRUCTS ---
+
+type adminTestCase struct {
+    testName    string
+    handler     handler
+    path        string
+    token       string
+    status      int
+    expected    []string
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+    "os"
+    "fmt"
+    "time"
+    "crypto"
+    "errors"
+    "net/url"
+    "crypto/rsa"
+    "crypto/rand"
+    "crypto/x509"
+    "encoding/pem"
+    "crypto/sha256"
+    "encoding/json"
+    "encoding/base64"
+
+    "golang.org/x/oauth2"
+    "github.com/spf13/viper"
+)
+
+// appTokenRefresh is how long before their expiry installation tokens are replaced.
+const appTokenRefresh = 5 * time.Minute
+
+// AppTokenSource mints installation tokens for a GitHub App, authenticating as the app with a JWT.
+type AppTokenSource struct {
+    AppID           int64
+    InstallationID  int64
+    Key             *rsa.PrivateKey
+
+    // BaseURL of the GitHub API the tokens are requested from, overriding github.base_url.
+    BaseURL         *url.URL
+}
+
+// appTokens issues the installation tokens of the configured GitHub App, if there is one.
+var appTokens oauth2.TokenSource
+
+// loadAppCredentials reads the GitHub App settings, if any, sharing a single token source between requests.
+func loadAppCredentials() error {
+    if !viper.IsSet("github.app.id") {
+        return nil
+    }
+
+    // Without an installation, every token would be requested for installation 0, and refused.
+    if viper.GetInt64("github.app.installation_id") <= 0 {
+        return fmt.Errorf("github.app.installation_id must be set along with github.app.id")
+    }
+
+    pemBytes := []byte(viper.GetString("github.app.private_key"))
+
+    if p := viper.GetString("github.app.private_key_path"); p != "" {
+        var err error
+        if pemBytes, err = os.ReadFile(p); err != nil {
+            return err
+        }
+    }
+
+    key, err := parsePrivateKey(pemBytes)
+
+    if err != nil {
+        return err
+    }
+
+    appTokens = oauth2.ReuseTokenSource(nil, &AppTokenSource{
+        AppID: viper.GetInt64("github.app.id"),
+        InstallationID: viper.GetInt64("github.app.installation_id"),
+        Key: key,
+    })
+
+    return nil
+}
+
+// Token exchanges a freshly minted JWT for an installation token.
+func (s *AppTokenSource) Token() (*oauth2.Token, error) {
+    jwt, err := s.JWT(time.Now())
+
+    if err != nil {
+        return nil, err
+    }
+
+    client := newGitHubClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(
+        &oauth2.Token{AccessToken: jwt},
+    )))
+
+    if s.BaseURL != nil {
+        client.BaseURL = s.BaseURL
+    }
+
+    t, _, err := client.Apps.CreateInstallationToken(ctx, s.InstallationID, nil)
+
+    if err != nil {
+        return nil, err
+    }
+
+    // Expire the token early, so it is never used right as GitHub stops accepting it.
+    return &oauth2.Token{
+        AccessToken: t.GetToken(),
+        Expiry: t.GetExpiresAt().Add(-appTokenRefresh),
+    }, nil
+}
+
+// JWT signs the token authenticating as the app itself, valid for 10 minutes at most.
+func (s *AppTokenSource) JWT(now time.Time) (string, error) {
+    header, _ := json.Marshal(map[string]string{
+        "alg": "RS256",
+        "typ": "JWT",
+    })
+
+    // Backdate the token, allowing for clock drift with GitHub.
+    claims, _ := json.Marshal(map[string]interface{}{
+        "iat": now.Add(-time.Minute).Unix(),
+        "exp": now.Add(9 * time.Minute).Unix(),
+        "iss": fmt.Sprint(s.AppID),
+    })
+
+    unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+    digest := sha256.Sum256([]byte(unsigned))
+    signature, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest[:])
+
+    if err != nil {
+        return "", err
+    }
+
+    return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey reads an RSA private key, in the PKCS #1 form GitHub generates or in PKCS #8.
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+    block, _ := pem.Decode(b)
+
+    if block == nil {
+        return nil, errors.New("the GitHub App private key is not PEM encoded")
+    }
+
+    if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+        return key, nil
+    }
+
+    key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+    if err != nil {
+        return nil, err
+    }
+
+    if key, ok := key.(*rsa.PrivateKey); ok {
+        return key, nil
+    }
+
+    return nil, errors.New("the GitHub App private key is not an RSA key")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+    "time"
+    "crypto"
+    "strings"
+    "testing"
+    "net/url"
+    "net/http"
+    "crypto/rsa"
+    "crypto/rand"
+    "crypto/x509"
+    "encoding/pem"
+    "crypto/sha256"
+    "encoding/json"
+    "encoding/base64"
+    "net/http/httptest"
+
+    "golang.org/x/oauth2"
+    "github.com/spf13/viper"
+)
+
+// -- TESTS --
+
+func TestAppTokenSource(t *testing.T) {
+    var appTokenSourceTestCases = []appTokenTestCase{
+        {
+            testName:   "A long-lived installation token should be reused",
+            lifetime:   time.Hour,
+            expected:   1,
+        },
+        {
+            testName:   "An installation token about to expire should be refreshed",
+            lifetime:   time.Minute,
+            expected:   2,
+        },
+    }
+
+    key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+    for _, c := range appTokenSourceTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            minted := 0
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                if r.Method != http.MethodPost || r.URL.Path != "/app/installations/2/access_tokens" {
+                    w.WriteHeader(http.StatusNotFound)
+                    return
+                }
+
+                if !verifyTestJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey, "1") {
+                    w.WriteHeader(http.StatusUnauthorized)
+                    return
+                }
+
+                minted++
+                w.WriteHeader(http.StatusCreated)
+                json.NewEncoder(w).Encode(map[string]interface{}{
+                    "token": "ghs_installation",
+                    "expires_at": time.Now().Add(c.lifetime),
+                })
+            }))
+            defer server.Close()
+
+            baseURL, _ := url.Parse(server.URL + "/")
+            ts := oauth2.ReuseTokenSource(nil, &AppTokenSource{
+                AppID: 1,
+                InstallationID: 2,
+                Key: key,
+                BaseURL: baseURL,
+            })
+
+            assertAppTokens(t, ts, func() int { return minted }, c.expected)
+        })
+    }
+}
+
+func TestParsePrivateKey(t *testing.T) {
+    key, _ := rsa.GenerateKey(rand.Reader, 2048)
+    pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+
+    var parsePrivateKeyTestCases = []privateKeyTestCase{
+        {
+            testName:   "A PKCS #1 key, as generated by GitHub, should be parsed",
+            pem:        pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+            expected:   true,
+        },
+        {
+            testName:   "A PKCS #8 key should be parsed",
+            pem:        pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+            expected:   true,
+        },
+        {
+            testName:   "A key that is not PEM encoded should not be parsed",
+            pem:        []byte("notakey"),
+            expected:   false,
+        },
+    }
+
+    for _, c := range parsePrivateKeyTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            if _, err := parsePrivateKey(c.pem); (err == nil) != c.expected {
+                t.Errorf("Key parsed (%v), expected (%v)", err == nil, c.expected)
+            }
+        })
+    }
+}
+
+func TestLoadAppCredentials(t *testing.T) {
+    key, _ := rsa.GenerateKey(rand.Reader, 2048)
+    pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+    var loadAppCredentialsTestCases = []appCredentialsTestCase{
+        {
+            testName:   "An app with an installation and a key should be loaded",
+            settings:   map[string]interface{}{"github.app.id": 1, "github.app.installation_id": 2, "github.app.private_key": pemKey},
+            valid:      true,
+        },
+        {
+            testName:   "An app without an installation should not be loaded",
+            settings:   map[string]interface{}{"github.app.id": 1, "github.app.private_key": pemKey},
+            valid:      false,
+        },
+        {
+            testName:   "An app with an invalid installation should not be loaded",
+            settings:   map[string]interface{}{"github.app.id": 1, "github.app.installation_id": "none", "github.app.private_key": pemKey},
+            valid:      false,
+        },
+    }
+
+    defer func(ts oauth2.TokenSource) { appTokens = ts }(appTokens)
+
+    for _, c := range loadAppCredentialsTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            for _, k := range []string{"github.app.id", "github.app.installation_id", "github.app.private_key"} {
+                defer viper.Set(k, viper.Get(k))
+                viper.Set(k, c.settings[k])
+            }
+
+            if err := loadAppCredentials(); (err == nil) != c.valid {
+                t.Errorf("App credentials loaded (%v), expected (%v)", err, c.valid)
+            }
+        })
+    }
+}
+
+// --- ASSERTS ---
+
+func assertAppTokens(t *testing.T, ts oauth2.TokenSource, minted func() int, expected int) {
+    for i := 0; i < 2; i++ {
+        token, err := ts.Token()
+
+        if err != nil {
+            t.Errorf("Installation token expected to be minted, but failed (%v)", err)
+            return
+        }
+
+        if token.AccessToken != "ghs_installation" {
+            t.Errorf("Installation token (%v) expected to be (%v)", token.AccessToken, "ghs_installation")
+        }
+    }
+
+    if minted() != expected {
+        t.Errorf("Installation tokens minted (%d) expected to be (%d)", minted(), expected)
+    }
+}
+
+// --- HELPERS ---
+
+// verifyTestJWT checks a JWT is signed by the app's key and issued by the app.
+func verifyTestJWT(jwt string, key *rsa.PublicKey, iss string) bool {
+    parts := strings.Split(jwt, ".")
+
+    if len(parts) != 3 {
+        return false
+    }
+
+    signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+    digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+    if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+        return false
+    }
+
+    var claims struct {
+        Iss string  `json:"iss"`
+        Iat int64   `json:"iat"`
+        Exp int64   `json:"exp"`
+    }
+
+    b, _ := base64.RawURLEncoding.DecodeString(parts[1])
+    json.Unmarshal(b, &claims)
+
+    return claims.Iss == iss && claims.Iat < time.Now().Unix() && claims.Exp > time.Now().Unix()
+}
+
+// --- STRUCTS ---
+
+type appTokenTestCase struct {
+    testName    string
+    lifetime    time.Duration
+    expected    int
+}
+
+type privateKeyTestCase struct {
+    testName    string
+    pem         []byte
+    expected    bool
+}
+
+type appCredentialsTestCase struct {
+    testName    string
+    settings    map[string]interface{}
+    valid       bool
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+    "io"
+    "os"
+    "log"
+    "fmt"
+    "path"
+    "sync"
+    "time"
+    "bytes"
+    "context"
+    "strings"
+    "net/http"
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+// ArchiveSource serves the catalog from an in-memory index of every file in an archive of the repository.
+type ArchiveSource struct {
+    // Path of a local .tar.gz or .zip archive. When empty, the archive is downloaded from GitHub.
+    Path            string
+    Client          *github.Client
+    User            string
+    Name            string
+
+    mu              sync.RWMutex
+    files           map[string][]byte
+    dirs            map[string][]*github.RepositoryContent
+}
+
+func newArchiveSource() (*ArchiveSource, error) {
+    s := &ArchiveSource{
+        Path: viper.GetString("source.path"),
+        Client: authorize(""),
+        User: viper.GetString("repository.user"),
+        Name: viper.GetString("repository.name"),
+    }
+
+    if err := s.Refresh(ctx); err != nil {
+        return nil, err
+    }
+
+    go s.refreshEvery(sourceRefresh())
+
+    return s, nil
+}
+
+func (s *ArchiveSource) Languages(ctx context.Context) ([]*Language, error) {
+    b, ok := s.file("README.md")
+
+    if !ok {
+        return nil, notFound()
+    }
+
+    return findLanguages(string(b)), nil
+}
+
+func (s *ArchiveSource) Language(ctx context.Context, l string) (*Language, error) {
+    s.mu.RLock()
+    dir := s.dirs[bucket(l)]
+    s.mu.RUnlock()
+
+    return findLanguage(dir, l)
+}
+
+func (s *ArchiveSource) Code(ctx context.Context, language *Language) (*Code, error) {
+    b, ok := s.file(languagePath(language))
+
+    if !ok {
+        return nil, notFound()
+    }
+
+    return &Code{
+        Contents: string(b),
+    }, nil
+}
+
+// Refresh reads the archive again and replaces the index with its files. The cached languages whose files
+// changed since the previous read are invalidated, along with the catalog when it may have changed.
+func (s *ArchiveSource) Refresh(ctx context.Context) error {
+    b, err := s.read(ctx)
+
+    if err != nil {
+        return err
+    }
+
+    files, err := readArchive(b)
+
+    if err != nil {
+        return err
+    }
+
+    dirs := make(map[string][]*github.RepositoryContent)
+    for p := range files {
+        dirs[path.Dir(p)] = append(dirs[path.Dir(p)], &github.RepositoryContent{
+            Name: github.String(path.Base(p)),
+        })
+    }
+
+    s.mu.Lock()
+    previous := s.files
+    s.files = files
+    s.dirs = dirs
+    s.mu.Unlock()
+
+    // The first read replaces nothing the cache could hold.
+    if previous == nil {
+        return nil
+    }
+
+    // The changes between two reads are invalidated the way those of a push are.
+    for _, key := range pushedKeys(&github.PushEvent{
+        Commits: []*github.HeadCommit{archiveChanges(previous, files)},
+    }) {
+        invalidate(key)
+    }
+
+    return nil
+}
+
+// archiveChanges lists the files added, removed and modified between two reads of an archive, as a commit would.
+func archiveChanges(previous map[string][]byte, files map[string][]byte) *github.HeadCommit {
+    c := &github.HeadCommit{}
+
+    for p, b := range files {
+        if old, ok := previous[p]; !ok {
+            c.Added = append(c.Added, p)
+        } else if !bytes.Equal(old, b) {
+            c.Modified = append(c.Modified, p)
+        }
+    }
+
+    for p := range previous {
+        if _, ok := files[p]; !ok {
+            c.Removed = append(c.Removed, p)
+        }
+    }
+
+    return c
+}
+
+func (s *ArchiveSource) file(p string) ([]byte, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    b, ok := s.files[p]
+    return b, ok
+}
+
+// read returns the raw archive, either from disk or downloaded from GitHub.
+func (s *ArchiveSource) read(ctx context.Context) ([]byte, error) {
+    if s.Path != "" {
+        return os.ReadFile(s.Path)
+    }
+
+    u, _, err := s.Client.Repositories.GetArchiveLink(ctx, s.User, s.Name, github.Tarball, nil, true)
+
+    if err != nil {
+        return nil, err
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+
+    if err != nil {
+        return nil, err
+    }
+
+    resp, err := http.DefaultClient.Do(req)
+
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("downloading archive: %s", resp.Status)
+    }
+
+    return io.ReadAll(resp.Body)
+}
+
+func (s *ArchiveSource) refreshEvery(interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+
+    for range time.Tick(interval) {
+        if err := s.Refresh(ctx); err != nil {
+            log.Printf("Refreshing the archive failed: %v", err)
+        }
+    }
+}
+
+// readArchive indexes the regular files of a gzipped tarball or a zip archive by their path.
+// The top-level directory GitHub wraps archives in is removed from the paths.
+func readArchive(b []byte) (files map[string][]byte, err error) {
+    switch {
+    case bytes.HasPrefix(b, []byte("\x1f\x8b")):
+        files, err = readTarball(b)
+    case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+        files, err = readZipball(b)
+    default:
+        err = fmt.Errorf("unknown archive format")
+    }
+
+    if err != nil {
+        return nil, err
+    }
+
+    return trimArchiveRoot(files), nil
+}
+
+func readTarball(b []byte) (map[string][]byte, error) {
+    gz, err := gzip.NewReader(bytes.NewReader(b))
+
+    if err != nil {
+        return nil, err
+    }
+
+    files := make(map[string][]byte)
+    tr := tar.NewReader(gz)
+
+    for {
+        h, err := tr.Next()
+
+        if err == io.EOF {
+            return files, nil
+        }
+
+        if err != nil {
+            return nil, err
+        }
+
+        if h.Typeflag != tar.TypeReg {
+            continue
+        }
+
+        if files[h.Name], err = io.ReadAll(tr); err != nil {
+            return nil, err
+        }
+    }
+}
+
+func readZipball(b []byte) (map[string][]byte, error) {
+    zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+
+    if err != nil {
+        return nil, err
+    }
+
+    files := make(map[string][]byte)
+
+    for _, f := range zr.File {
+        if f.FileInfo().IsDir() {
+            continue
+        }
+
+        rc, err := f.Open()
+
+        if err != nil {
+            return nil, err
+        }
+
+        files[f.Name], err = io.ReadAll(rc)
+        rc.Close()
+
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    return files, nil
+}
+
+// trimArchiveRoot removes the directory every file is nested in, if there is one.
+func trimArchiveRoot(files map[string][]byte) map[string][]byte {
+    root := ""
+
+    for p := range files {
+        i := strings.Index(p, "/")
+
+        if i < 0 || (root != "" && root != p[:i + 1]) {
+            return files
+        }
+
+        root = p[:i + 1]
+    }
+
+    trimmed := make(map[string][]byte, len(files))
+    for p, b := range files {
+        trimmed[strings.TrimPrefix(p, root)] = b
+    }
+
+    return trimmed
+}
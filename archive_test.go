@@ -0,0 +1,195 @@
+package main
+
+import (
+    "os"
+    "time"
+    "bytes"
+    "reflect"
+    "testing"
+    "archive/tar"
+    "archive/zip"
+    "path/filepath"
+    "compress/gzip"
+)
+
+// -- TESTS --
+
+func TestArchiveSource(t *testing.T) {
+    var archiveSourceTestCases = []archiveTestCase{
+        {
+            testName:   "A gzipped tarball wrapped in a directory should be indexed",
+            name:       "repo.tar.gz",
+            archive:    testTarball(t, "user-repo-1a2b3c/", testRepository),
+        },
+        {
+            testName:   "A zip archive wrapped in a directory should be indexed",
+            name:       "repo.zip",
+            archive:    testZipball(t, "user-repo-1a2b3c/"),
+        },
+        {
+            testName:   "A gzipped tarball without a directory should be indexed",
+            name:       "flat.tar.gz",
+            archive:    testTarball(t, "", testRepository),
+        },
+    }
+
+    dir := t.TempDir()
+
+    for _, c := range archiveSourceTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            p := filepath.Join(dir, c.name)
+            os.WriteFile(p, c.archive, 0644)
+
+            src := &ArchiveSource{
+                Path: p,
+            }
+
+            if err := src.Refresh(ctx); err != nil {
+                t.Errorf("Archive (%v) expected to be read, but failed (%v)", c.name, err)
+                return
+            }
+
+            assertSourceLanguages(t, src, []string{"Go", "Node.js", "μλ"})
+            assertSourceCode(t, src, "go", "package main\n")
+            assertSourceCode(t, src, "μλ", "Hello World\n")
+            assertSourceCode(t, src, "notalang", "")
+        })
+    }
+
+    t.Run("A file that is not an archive should not be read", func(t *testing.T) {
+        p := filepath.Join(dir, "README.md")
+        os.WriteFile(p, []byte(testRepository["README.md"]), 0644)
+
+        if err := (&ArchiveSource{Path: p}).Refresh(ctx); err == nil {
+            t.Errorf("File (%v) expected not to be read as an archive", p)
+        }
+    })
+}
+
+func TestArchiveRefresh(t *testing.T) {
+    var archiveRefreshTestCases = []archiveRefreshTestCase{
+        {
+            testName:   "An unchanged archive should invalidate nothing",
+            changes:    map[string]string{},
+            expected:   []string{},
+        },
+        {
+            testName:   "Editing a language should only invalidate it",
+            changes:    map[string]string{"g/Go.go": "package hello\n"},
+            expected:   []string{"language-go"},
+        },
+        {
+            testName:   "Removing a language should invalidate it and the catalog",
+            changes:    map[string]string{"n/Node.js.js": ""},
+            expected:   []string{"languages", "language-node.js"},
+        },
+        {
+            testName:   "Editing the README should invalidate the catalog",
+            changes:    map[string]string{"README.md": "* [Go](g/Go.go)\n"},
+            expected:   []string{"languages"},
+        },
+    }
+
+    p := filepath.Join(t.TempDir(), "repo.tar.gz")
+    keys := []string{"languages", languageKey("go"), languageKey("node.js"), languageKey("μλ")}
+
+    for _, c := range archiveRefreshTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+
+            os.WriteFile(p, testTarball(t, "", testRepository), 0644)
+            src := &ArchiveSource{Path: p}
+            src.Refresh(ctx)
+
+            for _, key := range keys {
+                cacheSet(key, LanguageResponse{CachedAt: time.Now()})
+            }
+
+            // An empty change removes the file.
+            files := make(map[string]string)
+            for f, contents := range testRepository {
+                files[f] = contents
+            }
+            for f, contents := range c.changes {
+                if files[f] = contents; contents == "" {
+                    delete(files, f)
+                }
+            }
+
+            os.WriteFile(p, testTarball(t, "", files), 0644)
+            if err := src.Refresh(ctx); err != nil {
+                t.Errorf("Archive expected to be read again, but failed (%v)", err)
+                return
+            }
+
+            invalidated := []string{}
+            for _, key := range keys {
+                if _, err := cache.Get(key); err != nil {
+                    invalidated = append(invalidated, key)
+                }
+            }
+
+            if !reflect.DeepEqual(invalidated, c.expected) {
+                t.Errorf("Invalidated keys (%v) expected to be (%v)", invalidated, c.expected)
+            }
+        })
+    }
+}
+
+// --- HELPERS ---
+
+func testTarball(t *testing.T, root string, files map[string]string) []byte {
+    var b bytes.Buffer
+    gz := gzip.NewWriter(&b)
+    tw := tar.NewWriter(gz)
+
+    if root != "" {
+        tw.WriteHeader(&tar.Header{Name: root, Typeflag: tar.TypeDir, Mode: 0755})
+    }
+
+    for p, c := range files {
+        tw.WriteHeader(&tar.Header{Name: root + p, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(c))})
+        tw.Write([]byte(c))
+    }
+
+    if err := tw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    gz.Close()
+
+    return b.Bytes()
+}
+
+func testZipball(t *testing.T, root string) []byte {
+    var b bytes.Buffer
+    zw := zip.NewWriter(&b)
+
+    if root != "" {
+        zw.Create(root)
+    }
+
+    for p, c := range testRepository {
+        w, _ := zw.Create(root + p)
+        w.Write([]byte(c))
+    }
+
+    if err := zw.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    return b.Bytes()
+}
+
+// --- STRUCTS ---
+
+type archiveTestCase struct {
+    testName    string
+    name        string
+    archive     []byte
+}
+
+type archiveRefreshTestCase struct {
+    testName    string
+    changes     map[string]string
+    expected    []string
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+    "sync"
+    "time"
+    "errors"
+    "net/http"
+
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+var errCircuitOpen = errors.New("GitHub is unavailable, its circuit is open")
+
+// CircuitBreaker stops calling GitHub after consecutive failures, for a cooldown period. Once it is over, a single
+// request is let through: the circuit closes again if it succeeds, and stays open for another cooldown otherwise.
+type CircuitBreaker struct {
+    Threshold       int
+    Cooldown        time.Duration
+
+    mu              sync.Mutex
+    failures        int
+    openUntil       time.Time
+    probing         bool
+}
+
+// breaker guards every request to GitHub.
+var breaker = newCircuitBreaker()
+
+func newCircuitBreaker() *CircuitBreaker {
+    b := &CircuitBreaker{
+        Threshold: viper.GetInt("breaker.threshold"),
+        Cooldown: viper.GetDuration("breaker.cooldown"),
+    }
+
+    if b.Threshold <= 0 {
+        b.Threshold = 5
+    }
+
+    if b.Cooldown <= 0 {
+        b.Cooldown = 30 * time.Second
+    }
+
+    return b
+}
+
+// Allow reports whether a request may be sent.
+func (b *CircuitBreaker) Allow() bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if b.failures < b.Threshold {
+        return true
+    }
+
+    if time.Now().Before(b.openUntil) || b.probing {
+        return false
+    }
+
+    b.probing = true
+    return true
+}
+
+// Done records the outcome of a request that was allowed.
+func (b *CircuitBreaker) Done(failed bool) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    b.probing = false
+
+    if !failed {
+        b.failures = 0
+        return
+    }
+
+    b.failures++
+
+    if b.failures >= b.Threshold {
+        b.openUntil = time.Now().Add(b.Cooldown)
+    }
+}
+
+// Cancel records a request that was allowed, but given up by its caller: it counts neither as a failure nor as
+// a success, and lets another probe through.
+func (b *CircuitBreaker) Cancel() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    b.probing = false
+}
+
+// Open reports whether requests are currently being refused.
+func (b *CircuitBreaker) Open() bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    return b.failures >= b.Threshold && time.Now().Before(b.openUntil)
+}
+
+// breakerTransport sends requests through the circuit breaker, counting errors and server errors as failures.
+type breakerTransport struct {
+    Base            http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    b := breaker
+
+    if !b.Allow() {
+        return nil, errCircuitOpen
+    }
+
+    resp, err := t.Base.RoundTrip(req)
+
+    // A request cancelled by its caller says nothing about GitHub.
+    if err != nil && req.Context().Err() != nil {
+        b.Cancel()
+        return resp, err
+    }
+
+    b.Done(err != nil || resp.StatusCode >= 500)
+
+    return resp, err
+}
+
+// upstreamUnavailable reports whether an error means GitHub could not be reached or could not answer, rather than
+// it answering that the content does not exist or cannot be accessed.
+func upstreamUnavailable(err error) bool {
+    if _, ok := rateLimited(err); ok {
+        return true
+    }
+
+    var e *github.ErrorResponse
+    if errors.As(err, &e) {
+        return e.Response.StatusCode >= 500
+    }
+
+    var notFound *ErrorResponse
+    return !errors.As(err, &notFound)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+    "io"
+    "time"
+    "context"
+    "strings"
+    "testing"
+    "net/http"
+    "encoding/json"
+    "net/http/httptest"
+)
+
+// -- TESTS --
+
+func TestCircuitBreaker(t *testing.T) {
+    var circuitBreakerTestCases = []breakerTestCase{
+        {
+            testName:   "Failures under the threshold should keep the circuit closed",
+            outcomes:   []bool{true, true, false, true, true},
+            wait:       0,
+            expected:   false,
+        },
+        {
+            testName:   "Consecutive failures reaching the threshold should open the circuit",
+            outcomes:   []bool{true, true, true},
+            wait:       0,
+            expected:   true,
+        },
+        {
+            testName:   "A successful request after the cooldown should close the circuit",
+            outcomes:   []bool{true, true, true, false},
+            wait:       20 * time.Millisecond,
+            expected:   false,
+        },
+        {
+            testName:   "A failed request after the cooldown should open the circuit again",
+            outcomes:   []bool{true, true, true, true},
+            wait:       20 * time.Millisecond,
+            expected:   true,
+        },
+    }
+
+    for _, c := range circuitBreakerTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            b := &CircuitBreaker{Threshold: 3, Cooldown: 10 * time.Millisecond}
+
+            for i, failed := range c.outcomes {
+                // Wait for the cooldown before the last request.
+                if i == len(c.outcomes) - 1 && c.wait > 0 {
+                    time.Sleep(c.wait)
+                }
+
+                if !b.Allow() {
+                    t.Errorf("Request %d expected to be allowed", i)
+                    return
+                }
+
+                b.Done(failed)
+            }
+
+            assertCircuitOpen(t, b, c.expected)
+        })
+    }
+}
+
+func TestBreakerTransport(t *testing.T) {
+    var breakerTransportTestCases = []breakerTransportTestCase{
+        {
+            testName:   "A server error should count as a failure",
+            status:     http.StatusBadGateway,
+            cancel:     false,
+            failures:   1,
+            expected:   2,
+        },
+        {
+            testName:   "A successful response should close the circuit",
+            status:     http.StatusOK,
+            cancel:     false,
+            failures:   3,
+            expected:   0,
+        },
+        {
+            testName:   "A probe cancelled by its caller should leave the circuit as it was",
+            status:     http.StatusOK,
+            cancel:     true,
+            failures:   3,
+            expected:   3,
+        },
+        {
+            testName:   "A request cancelled by its caller should not reset the failures",
+            status:     http.StatusOK,
+            cancel:     true,
+            failures:   1,
+            expected:   1,
+        },
+    }
+
+    defer func(b *CircuitBreaker) { breaker = b }(breaker)
+
+    for _, c := range breakerTransportTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                if c.cancel {
+                    <-r.Context().Done()
+                }
+                w.WriteHeader(c.status)
+            }))
+            defer server.Close()
+
+            // The cooldown is over, so a request at the threshold is a probe.
+            breaker = &CircuitBreaker{Threshold: 3, Cooldown: time.Minute}
+            breaker.failures = c.failures
+            breaker.openUntil = time.Now().Add(-time.Second)
+
+            assertBreakerTransport(t, server.URL, c.cancel, c.expected)
+        })
+    }
+}
+
+func TestStaleFallback(t *testing.T) {
+    var staleFallbackTestCases = []staleTestCase{
+        {
+            testName:   "An expired language should be served as stale while the circuit is open",
+            err:        errCircuitOpen,
+            status:     http.StatusOK,
+            stale:      true,
+        },
+        {
+            testName:   "An expired language should be served as stale while GitHub is unreachable",
+            err:        context.DeadlineExceeded,
+            status:     http.StatusOK,
+            stale:      true,
+        },
+        {
+            testName:   "An expired language that no longer exists should not be served",
+            err:        notFound(),
+            status:     http.StatusNotFound,
+            stale:      false,
+        },
+    }
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+
+    for _, c := range staleFallbackTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            cacheSet(languageKey("go"), LanguageResponse{
+                Code: &Code{Contents: "package main\n"},
+                Language: &Language{Name: "Go", Extension: ".go"},
+                CachedAt: time.Now().Add(-2 * cacheTTL()),
+            })
+
+            newSource = func(string) Source { return &failingSource{err: c.err} }
+
+            assertStale(t, "/api/language/go", c.status, c.stale)
+        })
+    }
+}
+
+// --- ASSERTS ---
+
+func assertCircuitOpen(t *testing.T, b *CircuitBreaker, expected bool) {
+    if b.Open() != expected {
+        t.Errorf("Circuit open (%v) expected to be (%v)", b.Open(), expected)
+    }
+
+    if b.Allow() == expected {
+        t.Errorf("Requests allowed (%v) expected to be (%v)", !expected, expected)
+    }
+}
+
+func assertBreakerTransport(t *testing.T, url string, cancel bool, expected int) {
+    reqCtx, cancelReq := context.WithTimeout(ctx, time.Minute)
+    defer cancelReq()
+
+    if cancel {
+        reqCtx, cancelReq = context.WithTimeout(ctx, 20 * time.Millisecond)
+        defer cancelReq()
+    }
+
+    req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+    resp, err := (&breakerTransport{Base: http.DefaultTransport}).RoundTrip(req)
+
+    if err == nil {
+        resp.Body.Close()
+    }
+
+    breaker.mu.Lock()
+    defer breaker.mu.Unlock()
+
+    if breaker.failures != expected || breaker.probing {
+        t.Errorf("Failures (%d, probing %v) expected to be (%d), not probing", breaker.failures, breaker.probing, expected)
+    }
+}
+
+func assertStale(t *testing.T, path string, status int, stale bool) {
+    req := httptest.NewRequest("GET", "http://localhost:8080" + path, nil)
+    w := httptest.NewRecorder()
+    getLanguage(w, req)
+
+    resp := w.Result()
+    body, _ := io.ReadAll(resp.Body)
+
+    if resp.StatusCode != status {
+        t.Errorf("Status code (%d) expected to be %d", resp.StatusCode, status)
+        return
+    }
+
+    if (resp.Header.Get("Warning") != "") != stale {
+        t.Errorf("Warning (%v) expected to be present (%v)", resp.Header.Get("Warning"), stale)
+    }
+
+    if status != http.StatusOK {
+        return
+    }
+
+    raw := &LanguageResponse{}
+    if err := json.Unmarshal(body, &raw); err != nil {
+        t.Errorf("Response body (%s) expected to be marshalled into struct (%#v)", string(body), raw)
+        return
+    }
+
+    if raw.Stale != stale || raw.Code.Contents != "package main\n" {
+        t.Errorf("Response (%s) expected to be the cached language, stale (%v)", string(body), stale)
+    }
+
+    // Stale is named the way the other fields of the response are.
+    if !strings.Contains(string(body), `"Stale":`) || !strings.Contains(string(body), `"CachedAt":`) {
+        t.Errorf("Response (%s) expected to name its fields alike", string(body))
+    }
+}
+
+// --- HELPERS ---
+
+// failingSource is a source that cannot be read from.
+type failingSource struct {
+    err         error
+}
+
+func (s *failingSource) Languages(ctx context.Context) ([]*Language, error) {
+    return nil, s.err
+}
+
+func (s *failingSource) Language(ctx context.Context, l string) (*Language, error) {
+    return nil, s.err
+}
+
+func (s *failingSource) Code(ctx context.Context, language *Language) (*Code, error) {
+    return nil, s.err
+}
+
+// --- STRUCTS ---
+
+type breakerTestCase struct {
+    testName    string
+    outcomes    []bool
+    wait        time.Duration
+    expected    bool
+}
+
+type breakerTransportTestCase struct {
+    testName    string
+    status      int
+    cancel      bool
+    failures    int
+    expected    int
+}
+
+type staleTestCase struct {
+    testName    string
+    err         error
+    status      int
+    stale       bool
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/spf13/viper"
+    "github.com/allegro/bigcache/v3"
+)
+
+// A Cache stores the encoded responses by key. Its methods are those of bigcache, the default backend.
+type Cache interface {
+    Get(key string) ([]byte, error)
+    Set(key string, entry []byte) error
+    Delete(key string) error
+    Reset() error
+    Len() int
+}
+
+// ErrEntryNotFound is returned by every backend for keys it does not hold.
+var ErrEntryNotFound = bigcache.ErrEntryNotFound
+
+// newCache builds the configured type of cache, keeping entries for the retention period.
+func newCache() (Cache, error) {
+    switch t := viper.GetString("cache.type"); t {
+    case "", "bigcache":
+        return bigcache.New(ctx, bigcacheConfig())
+    case "lru":
+        return NewLRUCache(
+            viper.GetInt("cache.lru.max_entries"),
+            viper.GetInt("cache.lru.max_bytes"),
+            cacheRetention(),
+        ), nil
+    case "disk":
+        return NewDiskCache(viper.GetString("cache.disk.path"), cacheRetention())
+    default:
+        return nil, fmt.Errorf("unknown cache type %q", t)
+    }
+}
+
+// bigcacheConfig is the default bigcache configuration, with the configured number of shards, size bound and
+// cleanup window.
+func bigcacheConfig() bigcache.Config {
+    config := bigcache.DefaultConfig(cacheRetention())
+
+    if shards := viper.GetInt("cache.bigcache.shards"); shards > 0 {
+        config.Shards = shards
+    }
+
+    // In megabytes, unbounded when zero.
+    config.HardMaxCacheSize = viper.GetInt("cache.bigcache.hard_max_size")
+
+    if viper.IsSet("cache.bigcache.clean_window") {
+        config.CleanWindow = viper.GetDuration("cache.bigcache.clean_window")
+    }
+
+    return config
+}
+
+// checkCacheConfig checks the cache settings, so a misconfigured server fails to start rather than misbehaves.
+func checkCacheConfig() error {
+    for _, key := range []string{
+        "cache.ttl",
+        "cache.hard_ttl",
+        "cache.retention",
+        "cache.languages_ttl",
+        "cache.language_ttl",
+        "cache.negative_ttl",
+        "cache.bigcache.clean_window",
+    } {
+        if err := checkDuration(key); err != nil {
+            return err
+        }
+    }
+
+    if shards := viper.GetInt("cache.bigcache.shards"); shards < 0 || shards & (shards - 1) != 0 {
+        return fmt.Errorf("cache.bigcache.shards must be a power of two, not %d", shards)
+    }
+
+    if size := viper.GetInt("cache.bigcache.hard_max_size"); size < 0 {
+        return fmt.Errorf("cache.bigcache.hard_max_size must not be negative, not %d", size)
+    }
+
+    // Entries are dropped after the retention period, so they could not be served for as long as their hard TTL.
+    for _, ttl := range []time.Duration{languagesTTL(), languageTTL()} {
+        if hard := cacheHardTTL(ttl); hard > cacheRetention() {
+            return fmt.Errorf("cache.retention (%v) must be at least the hard TTL of every route (%v)", cacheRetention(), hard)
+        }
+    }
+
+    return nil
+}
+
+// checkDuration checks a duration setting parses, and is not negative.
+func checkDuration(key string) error {
+    if s, ok := viper.Get(key).(string); ok && s != "" {
+        if _, err := time.ParseDuration(s); err != nil {
+            return fmt.Errorf("%v: %w", key, err)
+        }
+    }
+
+    if d := viper.GetDuration(key); d < 0 {
+        return fmt.Errorf("%v must not be negative, not %v", key, d)
+    }
+
+    return nil
+}
+
+// rangeCache calls fn with every entry held by a cache.
+func rangeCache(c Cache, fn func(key string, entry []byte)) {
+    switch c := c.(type) {
+    case *bigcache.BigCache:
+        for it := c.Iterator(); it.SetNext(); {
+            if e, err := it.Value(); err == nil {
+                fn(e.Key(), e.Value())
+            }
+        }
+    case interface{ Range(func(string, []byte)) }:
+        c.Range(fn)
+    }
+}
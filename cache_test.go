@@ -0,0 +1,267 @@
+package main
+
+import (
+    "os"
+    "time"
+    "testing"
+
+    "github.com/spf13/viper"
+    "github.com/allegro/bigcache/v3"
+)
+
+// -- TESTS --
+
+func TestCacheBackends(t *testing.T) {
+    big, _ := bigcache.New(ctx, bigcache.DefaultConfig(time.Hour))
+    disk, _ := NewDiskCache(t.TempDir(), time.Hour)
+
+    var cacheBackendsTestCases = []cacheBackendTestCase{
+        {
+            testName:   "The bigcache backend should store, return and delete entries",
+            cache:      big,
+        },
+        {
+            testName:   "The LRU backend should store, return and delete entries",
+            cache:      NewLRUCache(0, 0, time.Hour),
+        },
+        {
+            testName:   "The disk backend should store, return and delete entries",
+            cache:      disk,
+        },
+    }
+
+    for _, c := range cacheBackendsTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            assertCacheBackend(t, c.cache)
+        })
+    }
+}
+
+func TestLRUCache(t *testing.T) {
+    var lruCacheTestCases = []lruTestCase{
+        {
+            testName:   "The least recently used entry should be evicted past the maximum number of entries",
+            maxEntries: 2,
+            maxBytes:   0,
+            expected:   []string{"b", "c"},
+        },
+        {
+            testName:   "The least recently used entries should be evicted past the maximum size",
+            maxEntries: 0,
+            maxBytes:   5,
+            expected:   []string{"c"},
+        },
+        {
+            testName:   "No entry should be evicted within the bounds",
+            maxEntries: 3,
+            maxBytes:   9,
+            expected:   []string{"a", "b", "c"},
+        },
+    }
+
+    for _, c := range lruCacheTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            lru := NewLRUCache(c.maxEntries, c.maxBytes, time.Hour)
+
+            lru.Set("a", []byte("aaa"))
+            lru.Set("b", []byte("bbb"))
+            lru.Get("a")
+            lru.Set("b", []byte("bbb"))
+            lru.Set("c", []byte("ccc"))
+
+            assertCacheKeys(t, lru, []string{"a", "b", "c"}, c.expected)
+        })
+    }
+}
+
+func TestCacheExpiry(t *testing.T) {
+    disk, _ := NewDiskCache(t.TempDir(), 10 * time.Millisecond)
+
+    var cacheExpiryTestCases = []cacheBackendTestCase{
+        {
+            testName:   "An LRU entry past its life window should not be returned",
+            cache:      NewLRUCache(0, 0, 10 * time.Millisecond),
+        },
+        {
+            testName:   "A disk entry past its life window should not be returned",
+            cache:      disk,
+        },
+    }
+
+    for _, c := range cacheExpiryTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            c.cache.Set("key", []byte("value"))
+            time.Sleep(20 * time.Millisecond)
+
+            assertCacheKeys(t, c.cache, []string{"key"}, []string{})
+        })
+    }
+}
+
+func TestDiskCachePersistence(t *testing.T) {
+    dir := t.TempDir()
+
+    before, _ := NewDiskCache(dir, time.Hour)
+    before.Set("language-c++", []byte("value"))
+
+    after, err := NewDiskCache(dir, time.Hour)
+
+    if err != nil {
+        t.Errorf("Disk cache expected to open (%v), but failed (%v)", dir, err)
+        return
+    }
+
+    assertCacheKeys(t, after, []string{"language-c++"}, []string{"language-c++"})
+
+    if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+        t.Errorf("Disk cache expected to hold a single file, not (%d)", len(entries))
+    }
+}
+
+func TestCheckCacheConfig(t *testing.T) {
+    var checkCacheConfigTestCases = []cacheConfigTestCase{
+        {
+            testName:   "The default settings should be valid",
+            settings:   map[string]interface{}{},
+            valid:      true,
+        },
+        {
+            testName:   "Per-route TTLs within the retention period should be valid",
+            settings:   map[string]interface{}{"cache.languages_ttl": "1h", "cache.language_ttl": "72h"},
+            valid:      true,
+        },
+        {
+            testName:   "A TTL which does not parse should not be valid",
+            settings:   map[string]interface{}{"cache.language_ttl": "a day"},
+            valid:      false,
+        },
+        {
+            testName:   "A negative TTL should not be valid",
+            settings:   map[string]interface{}{"cache.negative_ttl": "-1m"},
+            valid:      false,
+        },
+        {
+            testName:   "A hard TTL past the retention period should not be valid",
+            settings:   map[string]interface{}{"cache.languages_ttl": "100h"},
+            valid:      false,
+        },
+        {
+            testName:   "A number of shards which is not a power of two should not be valid",
+            settings:   map[string]interface{}{"cache.bigcache.shards": 1000},
+            valid:      false,
+        },
+        {
+            testName:   "A negative cache size should not be valid",
+            settings:   map[string]interface{}{"cache.bigcache.hard_max_size": -1},
+            valid:      false,
+        },
+    }
+
+    for _, c := range checkCacheConfigTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            for key, value := range c.settings {
+                defer viper.Set(key, viper.Get(key))
+                viper.Set(key, value)
+            }
+
+            if err := checkCacheConfig(); (err == nil) != c.valid {
+                t.Errorf("Settings (%v) expected to be valid (%v), not (%v)", c.settings, c.valid, err)
+            }
+        })
+    }
+}
+
+func TestRouteTTLs(t *testing.T) {
+    defer viper.Set("cache.languages_ttl", viper.Get("cache.languages_ttl"))
+    viper.Set("cache.languages_ttl", "1h")
+
+    if languagesTTL() != time.Hour || languageTTL() != cacheTTL() {
+        t.Errorf("TTLs (%v, %v) expected to be (%v, %v)", languagesTTL(), languageTTL(), time.Hour, cacheTTL())
+    }
+
+    if cacheHardTTL(languagesTTL()) != 2 * time.Hour {
+        t.Errorf("Hard TTL (%v) expected to be (%v)", cacheHardTTL(languagesTTL()), 2 * time.Hour)
+    }
+
+    if config := bigcacheConfig(); config.Shards != 1024 || config.HardMaxCacheSize != 0 {
+        t.Errorf("Default bigcache configuration (%+v) expected to be kept", config)
+    }
+}
+
+// --- ASSERTS ---
+
+func assertCacheBackend(t *testing.T, c Cache) {
+    if err := c.Set("language-go", []byte("package main")); err != nil {
+        t.Errorf("Entry expected to be stored, but failed (%v)", err)
+        return
+    }
+    c.Set("language-#", []byte("Hello World"))
+
+    if b, err := c.Get("language-go"); err != nil || string(b) != "package main" {
+        t.Errorf("Entry (%s) expected to be returned, but failed (%v)", b, err)
+    }
+
+    if _, err := c.Get("language-notalang"); err != ErrEntryNotFound {
+        t.Errorf("Missing entry expected to be not found, not (%v)", err)
+    }
+
+    if c.Len() != 2 {
+        t.Errorf("Length (%d) expected to be (%d)", c.Len(), 2)
+    }
+
+    if err := c.Delete("language-go"); err != nil {
+        t.Errorf("Entry expected to be deleted, but failed (%v)", err)
+    }
+
+    if _, err := c.Get("language-go"); err != ErrEntryNotFound {
+        t.Errorf("Deleted entry expected to be not found, not (%v)", err)
+    }
+
+    c.Reset()
+
+    if c.Len() != 0 {
+        t.Errorf("Length (%d) expected to be (%d) once reset", c.Len(), 0)
+    }
+}
+
+// assertCacheKeys checks which of the keys are still held by the cache.
+func assertCacheKeys(t *testing.T, c Cache, keys []string, expected []string) {
+    var held []string
+    for _, key := range keys {
+        if _, err := c.Get(key); err == nil {
+            held = append(held, key)
+        }
+    }
+
+    if len(held) != len(expected) {
+        t.Errorf("Keys held (%v) expected to be (%v)", held, expected)
+        return
+    }
+
+    for i := range held {
+        if held[i] != expected[i] {
+            t.Errorf("Keys held (%v) expected to be (%v)", held, expected)
+            return
+        }
+    }
+}
+
+// --- STRUCTS ---
+
+type cacheBackendTestCase struct {
+    testName    string
+    cache       Cache
+}
+
+type cacheConfigTestCase struct {
+    testName    string
+    settings    map[string]interface{}
+    valid       bool
+}
+
+type lruTestCase struct {
+    testName    string
+    maxEntries  int
+    maxBytes    int
+    expected    []string
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+    "os"
+    "fmt"
+    "strings"
+    "crypto/sha256"
+    "net/http"
+
+    "golang.org/x/oauth2"
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+// serviceHTTPClient returns an HTTP client carrying the service's own GitHub credentials, if there are any.
+func serviceHTTPClient() *http.Client {
+    if appTokens != nil {
+        return tokenClient(appTokens)
+    }
+
+    if tokenPool != nil {
+        return &http.Client{Transport: tokenPool}
+    }
+
+    t := viper.GetString("github.token")
+
+    if t == "" {
+        t = os.Getenv("GITHUB_TOKEN")
+    }
+
+    if t == "" {
+        return &http.Client{Transport: upstreamTransport()}
+    }
+
+    return tokenClient(oauth2.StaticTokenSource(
+        &oauth2.Token{AccessToken: t},
+    ))
+}
+
+// gitHubURLs are the API and upload URLs of a GitHub Enterprise Server, if one is configured instead of github.com.
+var gitHubURLs []string
+
+func loadGitHubURLs() error {
+    base := viper.GetString("github.base_url")
+
+    if base == "" {
+        gitHubURLs = nil
+        return nil
+    }
+
+    upload := viper.GetString("github.upload_url")
+
+    if upload == "" {
+        upload = base
+    }
+
+    // Check the URLs once, so clients can be built from them without errors later.
+    if _, err := github.NewEnterpriseClient(base, upload, nil); err != nil {
+        return err
+    }
+
+    gitHubURLs = []string{base, upload}
+
+    return nil
+}
+
+// newGitHubClient builds a GitHub API client for github.com, or for the configured GitHub Enterprise Server.
+func newGitHubClient(hc *http.Client) *github.Client {
+    if hc == nil {
+        hc = &http.Client{Transport: upstreamTransport()}
+    }
+
+    if gitHubURLs == nil {
+        return github.NewClient(hc)
+    }
+
+    client, _ := github.NewEnterpriseClient(gitHubURLs[0], gitHubURLs[1], hc)
+
+    return client
+}
+
+// callerTokensOverride reports whether a caller's own token is used instead of the service's credentials.
+func callerTokensOverride() bool {
+    return !viper.IsSet("github.caller_tokens") || viper.GetBool("github.caller_tokens")
+}
+
+// checkVisibility checks the visibility of the repository is one cache keys can be scoped by.
+func checkVisibility() error {
+    switch v := viper.GetString("repository.visibility"); v {
+    case "", "public", "private":
+        return nil
+    default:
+        return fmt.Errorf("unknown repository visibility %q", v)
+    }
+}
+
+// cacheScope returns the prefix of the cache keys of a caller, given its Authorization header.
+// Responses fetched with the service's credentials are shared by everyone. Those fetched with a caller's own token
+// are shared too, unless the repository is private, as different tokens may then see different content.
+func cacheScope(auth string) string {
+    t := callerToken(auth)
+
+    if t == "" || !callerTokensOverride() || viper.GetString("repository.visibility") != "private" {
+        return ""
+    }
+
+    return fmt.Sprintf("token:%x/", sha256.Sum256([]byte(t)))
+}
+
+// scopedKey returns the cache key of a response in the scope of a caller.
+func scopedKey(auth string, key string) string {
+    return cacheScope(auth) + key
+}
+
+// unscopedKey returns a cache key without the scope of its caller, if it has one.
+func unscopedKey(scoped string) string {
+    if i := strings.Index(scoped, "/"); strings.HasPrefix(scoped, "token:") && i >= 0 {
+        return scoped[i + 1:]
+    }
+
+    return scoped
+}
+
+// isScopedKey reports whether a cache key is that of a response in the scope of any caller.
+func isScopedKey(scoped string, key string) bool {
+    return scoped == key || (strings.HasPrefix(scoped, "token:") && strings.HasSuffix(scoped, "/" + key))
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+    "net/url"
+    "testing"
+    "net/http"
+    "encoding/json"
+    "net/http/httptest"
+
+    "github.com/spf13/viper"
+)
+
+// -- TESTS --
+
+func TestServiceCredentials(t *testing.T) {
+    var serviceCredentialsTestCases = []credentialsTestCase{
+        {
+            testName:   "An anonymous caller without a service token should stay anonymous",
+            token:      "",
+            service:    "",
+            override:   true,
+            expected:   "",
+        },
+        {
+            testName:   "An anonymous caller should use the service token",
+            token:      "",
+            service:    "service",
+            override:   true,
+            expected:   "Bearer service",
+        },
+        {
+            testName:   "A caller's token should override the service token",
+            token:      "Bearer caller",
+            service:    "service",
+            override:   true,
+            expected:   "Bearer caller",
+        },
+        {
+            testName:   "A caller's token should not override the service token when disallowed",
+            token:      "Bearer caller",
+            service:    "service",
+            override:   false,
+            expected:   "Bearer service",
+        },
+    }
+
+    defer viper.Set("github.token", viper.Get("github.token"))
+    defer viper.Set("github.caller_tokens", viper.Get("github.caller_tokens"))
+    t.Setenv("GITHUB_TOKEN", "")
+
+    for _, c := range serviceCredentialsTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            viper.Set("github.token", c.service)
+            viper.Set("github.caller_tokens", c.override)
+
+            assertCredentials(t, c.token, c.expected)
+        })
+    }
+}
+
+func TestEnterpriseURLs(t *testing.T) {
+    var enterpriseURLsTestCases = []enterpriseTestCase{
+        {
+            testName:   "A client without a base URL should call github.com",
+            baseURL:    "",
+            expected:   "https://api.github.com/",
+        },
+        {
+            testName:   "A client with a base URL should call the GitHub Enterprise Server API",
+            baseURL:    "https://github.example.com",
+            expected:   "https://github.example.com/api/v3/",
+        },
+    }
+
+    defer loadGitHubURLs()
+    defer viper.Set("github.base_url", viper.Get("github.base_url"))
+
+    for _, c := range enterpriseURLsTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            viper.Set("github.base_url", c.baseURL)
+
+            if err := loadGitHubURLs(); err != nil {
+                t.Errorf("URL (%v) expected to load, but failed (%v)", c.baseURL, err)
+                return
+            }
+
+            if u := authorize("").BaseURL.String(); u != c.expected {
+                t.Errorf("Base URL (%v) expected to be (%v)", u, c.expected)
+            }
+        })
+    }
+
+    t.Run("A request should reach a GitHub Enterprise Server stand-in", func(t *testing.T) {
+        var requested string
+        server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            requested = r.URL.Path
+            w.Write([]byte("{}"))
+        }))
+        defer server.Close()
+
+        viper.Set("github.base_url", server.URL)
+        loadGitHubURLs()
+        authorize("").Users.Get(ctx, "")
+
+        if requested != "/api/v3/user" {
+            t.Errorf("Path requested (%v) expected to be (%v)", requested, "/api/v3/user")
+        }
+    })
+
+    t.Run("An invalid base URL should not load", func(t *testing.T) {
+        viper.Set("github.base_url", "://github.example.com")
+
+        if err := loadGitHubURLs(); err == nil {
+            t.Errorf("Invalid URL expected not to load")
+        }
+    })
+}
+
+func TestCacheScope(t *testing.T) {
+    var cacheScopeTestCases = []cacheScopeTestCase{
+        {
+            testName:   "Callers of a public repository should share their responses",
+            token:      "Bearer caller",
+            visibility: "public",
+            override:   true,
+            shared:     true,
+        },
+        {
+            testName:   "Anonymous callers of a private repository should share their responses",
+            token:      "",
+            visibility: "private",
+            override:   true,
+            shared:     true,
+        },
+        {
+            testName:   "Callers of a private repository should not share the responses fetched with their token",
+            token:      "Bearer caller",
+            visibility: "private",
+            override:   true,
+            shared:     false,
+        },
+        {
+            testName:   "Callers of a private repository should share responses fetched with the service's credentials",
+            token:      "Bearer caller",
+            visibility: "private",
+            override:   false,
+            shared:     true,
+        },
+    }
+
+    defer viper.Set("repository.visibility", viper.Get("repository.visibility"))
+    defer viper.Set("github.caller_tokens", viper.Get("github.caller_tokens"))
+
+    for _, c := range cacheScopeTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            viper.Set("repository.visibility", c.visibility)
+            viper.Set("github.caller_tokens", c.override)
+
+            if shared := scopedKey(c.token, "languages") == "languages"; shared != c.shared {
+                t.Errorf("Cache key (%v) expected to be shared (%v)", scopedKey(c.token, "languages"), c.shared)
+            }
+        })
+    }
+
+    t.Run("A caller of a private repository should not be served another caller's response", func(t *testing.T) {
+        viper.Set("repository.visibility", "private")
+        viper.Set("github.caller_tokens", true)
+
+        defer func(fn func(string) Source) { newSource = fn }(newSource)
+        newSource = func(auth string) Source {
+            return &fakeSource{"fake": auth}
+        }
+
+        cache.Reset()
+        assertCallerCode(t, "Bearer first", "Bearer first")
+        assertCallerCode(t, "Bearer second", "Bearer second")
+        assertCallerCode(t, "Bearer first", "Bearer first")
+    })
+}
+
+// --- ASSERTS ---
+
+func assertCallerCode(t *testing.T, token string, expected string) {
+    req := httptest.NewRequest("GET", "http://localhost:8080/api/language/fake", nil)
+    req.Header.Set("Authorization", token)
+    w := httptest.NewRecorder()
+    getLanguage(w, req)
+
+    var res LanguageResponse
+    json.NewDecoder(w.Result().Body).Decode(&res)
+
+    if res.Code == nil || res.Code.Contents != expected {
+        t.Errorf("Code (%+v) served to (%v) expected to be (%v)", res.Code, token, expected)
+    }
+}
+
+func assertCredentials(t *testing.T, token string, expected string) {
+    var sent string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        sent = r.Header.Get("Authorization")
+        w.Write([]byte("{}"))
+    }))
+    defer server.Close()
+
+    client := authorize(token)
+    client.BaseURL, _ = url.Parse(server.URL + "/")
+    client.Users.Get(ctx, "")
+
+    if sent != expected {
+        t.Errorf("Authorization (%v) expected to be (%v)", sent, expected)
+    }
+}
+
+// --- STRUCTS ---
+
+type credentialsTestCase struct {
+    testName    string
+    token       string
+    service     string
+    override    bool
+    expected    string
+}
+
+type cacheScopeTestCase struct {
+    testName    string
+    token       string
+    visibility  string
+    override    bool
+    shared      bool
+}
+
+type enterpriseTestCase struct {
+    testName    string
+    baseURL     string
+    expected    string
+}
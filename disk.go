@@ -0,0 +1,124 @@
+package main
+
+import (
+    "os"
+    "time"
+    "path/filepath"
+    "encoding/hex"
+)
+
+// DiskCache stores every entry in its own file within a directory, so the cache outlives the process.
+type DiskCache struct {
+    Dir             string
+    LifeWindow      time.Duration
+}
+
+func NewDiskCache(dir string, lifeWindow time.Duration) (*DiskCache, error) {
+    if dir == "" {
+        dir = filepath.Join(os.TempDir(), "hwaas-api-cache")
+    }
+
+    if err := os.MkdirAll(dir, 0700); err != nil {
+        return nil, err
+    }
+
+    return &DiskCache{
+        Dir: dir,
+        LifeWindow: lifeWindow,
+    }, nil
+}
+
+func (c *DiskCache) Get(key string) ([]byte, error) {
+    p := c.path(key)
+    info, err := os.Stat(p)
+
+    if err != nil {
+        return nil, ErrEntryNotFound
+    }
+
+    // The modification time of a file is when its entry was stored.
+    if c.LifeWindow > 0 && time.Since(info.ModTime()) > c.LifeWindow {
+        os.Remove(p)
+        return nil, ErrEntryNotFound
+    }
+
+    b, err := os.ReadFile(p)
+
+    if os.IsNotExist(err) {
+        return nil, ErrEntryNotFound
+    }
+
+    return b, err
+}
+
+func (c *DiskCache) Set(key string, entry []byte) error {
+    // Write to a temporary file first, so readers never see a partial entry.
+    f, err := os.CreateTemp(c.Dir, ".tmp-")
+
+    if err != nil {
+        return err
+    }
+
+    _, err = f.Write(entry)
+
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+
+    if err != nil {
+        os.Remove(f.Name())
+        return err
+    }
+
+    return os.Rename(f.Name(), c.path(key))
+}
+
+func (c *DiskCache) Delete(key string) error {
+    err := os.Remove(c.path(key))
+
+    if os.IsNotExist(err) {
+        return ErrEntryNotFound
+    }
+
+    return err
+}
+
+func (c *DiskCache) Reset() error {
+    for _, key := range c.keys() {
+        os.Remove(c.path(key))
+    }
+
+    return nil
+}
+
+func (c *DiskCache) Len() int {
+    return len(c.keys())
+}
+
+// Range calls fn with every live entry.
+func (c *DiskCache) Range(fn func(key string, entry []byte)) {
+    for _, key := range c.keys() {
+        if b, err := c.Get(key); err == nil {
+            fn(key, b)
+        }
+    }
+}
+
+// keys lists the keys of the stored entries, from their file names.
+func (c *DiskCache) keys() []string {
+    entries, _ := os.ReadDir(c.Dir)
+
+    var keys []string
+    for _, e := range entries {
+        if key, err := hex.DecodeString(e.Name()); err == nil && !e.IsDir() {
+            keys = append(keys, string(key))
+        }
+    }
+
+    return keys
+}
+
+// path returns the file of an entry, named after its hex-encoded key so any key is a valid file name.
+func (c *DiskCache) path(key string) string {
+    return filepath.Join(c.Dir, hex.EncodeToString([]byte(key)))
+}
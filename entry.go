@@ -0,0 +1,130 @@
+package main
+
+import (
+    "io"
+    "time"
+    "bytes"
+    "errors"
+    "crypto/sha256"
+    "encoding/gob"
+    "compress/gzip"
+
+    "github.com/spf13/viper"
+)
+
+// A cache entry is an envelope around the gob-encoded response:
+//
+//     magic (3 bytes) | schema version (1) | flags (1) | SHA-256 of the payload, truncated (8) | payload
+//
+// Entries written before envelopes are plain gob, and are migrated when read.
+const (
+    entryMagic          = "hwc"
+    entryHeaderSize     = len(entryMagic) + 2 + entryHashSize
+    entryHashSize       = 8
+
+    // entryVersion is the schema version of the cached responses. Bump it when they change incompatibly,
+    // so the entries of the previous version are dropped instead of decoded into the wrong fields.
+    entryVersion        = 1
+
+    entryGzip           = 1 << 0
+)
+
+var (
+    errEntryOutdated    = errors.New("cache entry has an outdated schema version")
+    errEntryCorrupt     = errors.New("cache entry does not match its hash")
+
+    // errEntryLegacy is returned along with a decoded value, for an entry written before envelopes.
+    errEntryLegacy      = errors.New("cache entry has no envelope")
+)
+
+// encodeEntry encodes a response into an envelope, compressing it when it is large enough.
+func encodeEntry(value interface{}) ([]byte, error) {
+    payload := bytes.NewBuffer([]byte{})
+
+    if err := gob.NewEncoder(payload).Encode(value); err != nil {
+        return nil, err
+    }
+
+    var flags byte
+    b := payload.Bytes()
+
+    if len(b) >= compressMinSize() {
+        compressed := bytes.NewBuffer([]byte{})
+        gz := gzip.NewWriter(compressed)
+        gz.Write(b)
+
+        if err := gz.Close(); err != nil {
+            return nil, err
+        }
+
+        flags |= entryGzip
+        b = compressed.Bytes()
+    }
+
+    hash := sha256.Sum256(b)
+
+    entry := make([]byte, 0, entryHeaderSize + len(b))
+    entry = append(entry, entryMagic...)
+    entry = append(entry, entryVersion, flags)
+    entry = append(entry, hash[:entryHashSize]...)
+
+    return append(entry, b...), nil
+}
+
+// decodeEntry decodes an envelope into a response. An entry without an envelope is decoded as plain gob, returning
+// errEntryLegacy when it succeeds.
+func decodeEntry(entry []byte, value interface{}) error {
+    if !bytes.HasPrefix(entry, []byte(entryMagic)) || len(entry) < entryHeaderSize {
+        if err := gob.NewDecoder(bytes.NewReader(entry)).Decode(value); err != nil {
+            return err
+        }
+
+        return errEntryLegacy
+    }
+
+    header, b := entry[:entryHeaderSize], entry[entryHeaderSize:]
+
+    if header[len(entryMagic)] != entryVersion {
+        return errEntryOutdated
+    }
+
+    if hash := sha256.Sum256(b); !bytes.Equal(hash[:entryHashSize], header[len(entryMagic) + 2:]) {
+        return errEntryCorrupt
+    }
+
+    var r io.Reader = bytes.NewReader(b)
+
+    if header[len(entryMagic) + 1] & entryGzip != 0 {
+        gz, err := gzip.NewReader(r)
+
+        if err != nil {
+            return err
+        }
+        defer gz.Close()
+
+        r = gz
+    }
+
+    return gob.NewDecoder(r).Decode(value)
+}
+
+// entryCachedAt returns when the response of an entry was cached, without decoding the rest of it.
+func entryCachedAt(entry []byte) (time.Time, error) {
+    // Every cached response has a CachedAt, decoded on its own here.
+    var res struct { CachedAt time.Time }
+
+    if err := decodeEntry(entry, &res); err != nil && err != errEntryLegacy {
+        return time.Time{}, err
+    }
+
+    return res.CachedAt, nil
+}
+
+// compressMinSize is the size from which the payload of an entry is compressed.
+func compressMinSize() int {
+    if viper.IsSet("cache.compress_min_size") {
+        return viper.GetInt("cache.compress_min_size")
+    }
+
+    return 1024
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+    "time"
+    "bytes"
+    "testing"
+    "encoding/gob"
+
+    "github.com/spf13/viper"
+)
+
+// -- TESTS --
+
+func TestCacheEntry(t *testing.T) {
+    var cacheEntryTestCases = []entryTestCase{
+        {
+            testName:   "A small response should be stored as is",
+            minSize:    1024,
+            contents:   "package main\n",
+            compressed: false,
+        },
+        {
+            testName:   "A large response should be stored compressed",
+            minSize:    1024,
+            contents:   string(bytes.Repeat([]byte("console.log('Hello World')\n"), 100)),
+            compressed: true,
+        },
+        {
+            testName:   "Every response should be stored compressed from a size of zero",
+            minSize:    0,
+            contents:   "package main\n",
+            compressed: true,
+        },
+    }
+
+    defer viper.Set("cache.compress_min_size", viper.Get("cache.compress_min_size"))
+
+    for _, c := range cacheEntryTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            viper.Set("cache.compress_min_size", c.minSize)
+            assertEntryRoundTrip(t, c.contents, c.compressed)
+        })
+    }
+}
+
+func TestCacheEntryMigration(t *testing.T) {
+    var cacheEntryMigrationTestCases = []entryMigrationTestCase{
+        {
+            testName:   "An entry written before envelopes should be migrated",
+            entry:      testLegacyEntry("package main\n"),
+            expected:   "package main\n",
+        },
+        {
+            testName:   "An entry of an outdated schema version should be dropped",
+            entry:      testEntry("package main\n", func(e []byte) { e[len(entryMagic)] = entryVersion - 1 }),
+            expected:   "",
+        },
+        {
+            testName:   "An entry not matching its hash should be dropped",
+            entry:      testEntry("package main\n", func(e []byte) { e[len(e) - 1]++ }),
+            expected:   "",
+        },
+        {
+            testName:   "An entry which is not a response should be dropped",
+            entry:      []byte("not gob"),
+            expected:   "",
+        },
+    }
+
+    for _, c := range cacheEntryMigrationTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            cache.Set(languageKey("go"), c.entry)
+
+            assertEntryMigrated(t, languageKey("go"), c.expected)
+        })
+    }
+}
+
+// --- ASSERTS ---
+
+func assertEntryRoundTrip(t *testing.T, contents string, compressed bool) {
+    cachedAt := time.Now().Round(0)
+
+    entry, err := encodeEntry(LanguageResponse{Code: &Code{Contents: contents}, CachedAt: cachedAt})
+
+    if err != nil {
+        t.Errorf("Response expected to be encoded, but failed (%v)", err)
+        return
+    }
+
+    if c := entry[len(entryMagic) + 1] & entryGzip != 0; c != compressed {
+        t.Errorf("Entry expected to be compressed (%v), not (%v)", compressed, c)
+    }
+
+    var res LanguageResponse
+    if err := decodeEntry(entry, &res); err != nil || res.Code.Contents != contents || !res.CachedAt.Equal(cachedAt) {
+        t.Errorf("Entry expected to decode into the response, but failed (%v)", err)
+    }
+}
+
+func assertEntryMigrated(t *testing.T, key string, expected string) {
+    var res LanguageResponse
+    err := cacheGet(key, &res)
+    entry, stored := cache.Get(key)
+
+    if expected == "" {
+        if err == nil || stored == nil {
+            t.Errorf("Entry expected to be dropped, but was served (%v)", res.Code)
+        }
+        return
+    }
+
+    if err != nil || res.Code.Contents != expected {
+        t.Errorf("Entry expected to be served (%q), but failed (%v)", expected, err)
+        return
+    }
+
+    if !bytes.HasPrefix(entry, []byte(entryMagic)) {
+        t.Errorf("Entry expected to be stored again in an envelope")
+    }
+}
+
+// --- HELPERS ---
+
+func testLegacyEntry(contents string) []byte {
+    buffer := bytes.NewBuffer([]byte{})
+    gob.NewEncoder(buffer).Encode(LanguageResponse{Code: &Code{Contents: contents}, CachedAt: time.Now()})
+
+    return buffer.Bytes()
+}
+
+// testEntry encodes a response into an envelope, altered by a function.
+func testEntry(contents string, alter func([]byte)) []byte {
+    entry, _ := encodeEntry(LanguageResponse{Code: &Code{Contents: contents}, CachedAt: time.Now()})
+    alter(entry)
+
+    return entry
+}
+
+// --- STRUCTS ---
+
+type entryTestCase struct {
+    testName    string
+    minSize     int
+    contents    string
+    compressed  bool
+}
+
+type entryMigrationTestCase struct {
+    testName    string
+    entry       []byte
+    expected    string
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+    "os"
+    "context"
+    "path/filepath"
+
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+// FilesystemSource reads the catalog from a checked-out copy of the repository.
+type FilesystemSource struct {
+    Root            string
+}
+
+func newFilesystemSource(auth string) Source {
+    return &FilesystemSource{
+        Root: viper.GetString("source.path"),
+    }
+}
+
+func (s *FilesystemSource) Languages(ctx context.Context) ([]*Language, error) {
+    b, err := os.ReadFile(filepath.Join(s.Root, "README.md"))
+
+    if err != nil {
+        return nil, fsError(err)
+    }
+
+    return findLanguages(string(b)), nil
+}
+
+func (s *FilesystemSource) Language(ctx context.Context, l string) (*Language, error) {
+    entries, err := os.ReadDir(filepath.Join(s.Root, bucket(l)))
+
+    if err != nil {
+        return nil, fsError(err)
+    }
+
+    // Describe the directory the way the GitHub API would, so it is searched the same way.
+    var dir []*github.RepositoryContent
+    for _, e := range entries {
+        if !e.IsDir() {
+            dir = append(dir, &github.RepositoryContent{
+                Name: github.String(e.Name()),
+            })
+        }
+    }
+
+    return findLanguage(dir, l)
+}
+
+func (s *FilesystemSource) Code(ctx context.Context, language *Language) (*Code, error) {
+    b, err := os.ReadFile(filepath.Join(s.Root, filepath.FromSlash(languagePath(language))))
+
+    if err != nil {
+        return nil, fsError(err)
+    }
+
+    return &Code{
+        Contents: string(b),
+    }, nil
+}
+
+// fsError reports files missing from the checkout as not found.
+func fsError(err error) error {
+    if os.IsNotExist(err) {
+        return notFound()
+    }
+
+    return err
+}
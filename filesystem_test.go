@@ -0,0 +1,71 @@
+package main
+
+import (
+    "os"
+    "testing"
+    "net/http"
+    "path/filepath"
+    "net/http/httptest"
+)
+
+// -- TESTS --
+
+func TestFilesystemSource(t *testing.T) {
+    var filesystemSourceTestCases = []sourceTestCase{
+        {
+            testName:   "A language properly named should be resolved with its code",
+            language:   "GO",
+            expected:   "package main\n",
+        },
+        {
+            testName:   "A language with special characters should be resolved from the '#' directory",
+            language:   "μλ",
+            expected:   "Hello World\n",
+        },
+        {
+            testName:   "A language improperly named should not be resolved",
+            language:   "notalang",
+            expected:   "",
+        },
+        {
+            testName:   "A language in a missing directory should not be resolved",
+            language:   "zzz",
+            expected:   "",
+        },
+    }
+
+    root := t.TempDir()
+    for p, c := range testRepository {
+        f := filepath.Join(root, filepath.FromSlash(p))
+        os.MkdirAll(filepath.Dir(f), 0755)
+        os.WriteFile(f, []byte(c), 0644)
+    }
+
+    src := &FilesystemSource{
+        Root: root,
+    }
+
+    t.Run("The catalog should list every language in the README", func(t *testing.T) {
+        assertSourceLanguages(t, src, []string{"Go", "Node.js", "μλ"})
+    })
+
+    for _, c := range filesystemSourceTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            assertSourceCode(t, src, c.language, c.expected)
+        })
+    }
+
+    t.Run("A language missing from the README should still be served once the catalog is cached", func(t *testing.T) {
+        os.WriteFile(filepath.Join(root, "g", "Gleam.gleam"), []byte("pub fn main() {}\n"), 0644)
+
+        defer func(fn func(string) Source) { newSource = fn }(newSource)
+        newSource = func(string) Source {
+            return src
+        }
+
+        cache.Reset()
+        getLanguages(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080/api/languages", nil))
+
+        assertSourceRoute(t, getLanguage, "/api/language/gleam", http.StatusOK, "pub fn main() {}\n")
+    })
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+    "sync"
+    "context"
+)
+
+// flightGroup coalesces concurrent calls sharing a key into a single call, whose result they all get.
+type flightGroup struct {
+    mu              sync.Mutex
+    flights         map[string]*flight
+}
+
+type flight struct {
+    done            chan struct{}
+    res             interface{}
+    err             error
+}
+
+// flights coalesces the upstream fetches of every cache key.
+var flights = &flightGroup{}
+
+// Do calls fn, unless a call with the same key is in flight, and waits for its result. The call runs on its own,
+// so a caller giving up (when its context is done) neither cancels it nor affects the other callers.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+    g.mu.Lock()
+
+    if g.flights == nil {
+        g.flights = make(map[string]*flight)
+    }
+
+    f, ok := g.flights[key]
+
+    if !ok {
+        f = &flight{done: make(chan struct{})}
+        g.flights[key] = f
+
+        go func() {
+            f.res, f.err = fn()
+
+            g.mu.Lock()
+            delete(g.flights, key)
+            g.mu.Unlock()
+
+            close(f.done)
+        }()
+    }
+
+    g.mu.Unlock()
+
+    select {
+    case <-f.done:
+        return f.res, f.err
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+}
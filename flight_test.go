@@ -0,0 +1,196 @@
+package main
+
+import (
+    "fmt"
+    "sync"
+    "time"
+    "context"
+    "strings"
+    "testing"
+    "net/http"
+    "sync/atomic"
+    "net/http/httptest"
+)
+
+// -- TESTS --
+
+func TestFlightGroup(t *testing.T) {
+    var flightGroupTestCases = []flightTestCase{
+        {
+            testName:   "Concurrent calls with the same key should be coalesced into one",
+            callers:    100,
+            cancelled:  0,
+            expected:   1,
+        },
+        {
+            testName:   "Callers giving up should not cancel the call for the others",
+            callers:    10,
+            cancelled:  5,
+            expected:   1,
+        },
+    }
+
+    for _, c := range flightGroupTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            g := &flightGroup{}
+            release := make(chan struct{})
+            var calls int32
+
+            fn := func() (interface{}, error) {
+                atomic.AddInt32(&calls, 1)
+                <-release
+                return "result", nil
+            }
+
+            var wg sync.WaitGroup
+            results := make([]interface{}, c.callers)
+            errs := make([]error, c.callers)
+
+            for i := 0; i < c.callers; i++ {
+                ctx, cancel := context.WithCancel(context.Background())
+                if i < c.cancelled {
+                    cancel()
+                } else {
+                    defer cancel()
+                }
+
+                wg.Add(1)
+                go func(i int, ctx context.Context) {
+                    defer wg.Done()
+                    results[i], errs[i] = g.Do(ctx, "key", fn)
+                }(i, ctx)
+            }
+
+            // Let every caller join the flight before it lands.
+            time.Sleep(20 * time.Millisecond)
+            close(release)
+            wg.Wait()
+
+            assertFlight(t, atomic.LoadInt32(&calls), results, errs, c.cancelled, c.expected)
+        })
+    }
+}
+
+func TestGetLanguageCoalescing(t *testing.T) {
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+
+    var calls int32
+    newSource = func(string) Source {
+        return &slowSource{Source: &fakeSource{"fake": "print('Hello World')"}, calls: &calls}
+    }
+
+    cache.Reset()
+
+    var wg sync.WaitGroup
+    for i := 0; i < 50; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            assertSourceRoute(t, getLanguage, "/api/language/fake", http.StatusOK, "print('Hello World')")
+        }()
+    }
+    wg.Wait()
+
+    // One call to resolve the language, one to fetch its code.
+    if atomic.LoadInt32(&calls) != 2 {
+        t.Errorf("Calls to the source (%d) expected to be (%d)", calls, 2)
+    }
+
+    t.Run("A cancelled request should not be answered, nor cancel the fetch", func(t *testing.T) {
+        cache.Reset()
+
+        ctx, cancel := context.WithCancel(context.Background())
+        cancel()
+
+        req := httptest.NewRequest("GET", "http://localhost:8080/api/language/fake", nil).WithContext(ctx)
+        w := httptest.NewRecorder()
+        getLanguage(w, req)
+
+        if w.Body.Len() != 0 {
+            t.Errorf("Response body (%s) expected to be empty", w.Body.String())
+        }
+
+        assertRevalidated(t, languageKey("fake"), "print('Hello World')")
+    })
+}
+
+func TestGetLanguageCoalescingNotFound(t *testing.T) {
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+
+    var calls int32
+    newSource = func(string) Source {
+        return &slowSource{Source: &fakeSource{"fake": "print('Hello World')"}, calls: &calls}
+    }
+
+    cache.Reset()
+
+    // Every caller shares the error of the lookup, but is answered with its own request.
+    var wg sync.WaitGroup
+    for i := 0; i < 20; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+
+            path := fmt.Sprintf("/api/language/nope?caller=%d", i)
+            req := httptest.NewRequest("GET", "http://localhost:8080" + path, nil)
+            w := httptest.NewRecorder()
+            getLanguage(w, req)
+
+            if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), path) {
+                t.Errorf("Response (%d %s) expected to be a 404 for (%v)", w.Code, w.Body.String(), path)
+            }
+        }(i)
+    }
+    wg.Wait()
+
+    if atomic.LoadInt32(&calls) != 1 {
+        t.Errorf("Calls to the source (%d) expected to be (%d)", calls, 1)
+    }
+}
+
+// --- ASSERTS ---
+
+func assertFlight(t *testing.T, calls int32, results []interface{}, errs []error, cancelled int, expected int32) {
+    if calls != expected {
+        t.Errorf("Calls (%d) expected to be (%d)", calls, expected)
+    }
+
+    for i := range results {
+        if i < cancelled {
+            if errs[i] != context.Canceled {
+                t.Errorf("Cancelled caller %d expected to get (%v), got (%v)", i, context.Canceled, errs[i])
+            }
+        } else if results[i] != "result" || errs[i] != nil {
+            t.Errorf("Caller %d expected to get the shared result, got (%v, %v)", i, results[i], errs[i])
+        }
+    }
+}
+
+// --- HELPERS ---
+
+// slowSource counts the calls made to a source, each taking a while.
+type slowSource struct {
+    Source
+    calls       *int32
+}
+
+func (s *slowSource) Language(ctx context.Context, l string) (*Language, error) {
+    atomic.AddInt32(s.calls, 1)
+    time.Sleep(20 * time.Millisecond)
+    return s.Source.Language(ctx, l)
+}
+
+func (s *slowSource) Code(ctx context.Context, language *Language) (*Code, error) {
+    atomic.AddInt32(s.calls, 1)
+    time.Sleep(20 * time.Millisecond)
+    return s.Source.Code(ctx, language)
+}
+
+// --- STRUCTS ---
+
+type flightTestCase struct {
+    testName    string
+    callers     int
+    cancelled   int
+    expected    int32
+}
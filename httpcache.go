@@ -0,0 +1,70 @@
+package main
+
+import (
+    "fmt"
+    "time"
+    "strings"
+    "net/http"
+    "crypto/sha256"
+    "encoding/json"
+)
+
+// writeCacheHeaders sets the ETag, Last-Modified and Cache-Control headers of a cached response, and answers
+// 304 Not Modified when the request's validators still match it. It reports whether the response was written.
+func writeCacheHeaders(w http.ResponseWriter, r *http.Request, content interface{}, cachedAt time.Time, ttl time.Duration) bool {
+    etag := contentETag(content)
+    lastModified := cachedAt.UTC().Truncate(time.Second)
+
+    w.Header().Set("ETag", etag)
+    w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+    // Responses fetched with a caller's own token must not be stored by shared caches.
+    visibility := "public"
+    if cacheScope(r.Header.Get("Authorization")) != "" {
+        visibility = "private"
+    }
+
+    maxAge := ttl - time.Since(cachedAt)
+    if maxAge < 0 {
+        maxAge = 0
+    }
+
+    w.Header().Set("Cache-Control", fmt.Sprintf("%v, max-age=%d", visibility, int(maxAge.Seconds())))
+
+    if !isNotModified(r, etag, lastModified) {
+        return false
+    }
+
+    w.Header().Del("Content-Type")
+    w.WriteHeader(http.StatusNotModified)
+
+    return true
+}
+
+// isNotModified evaluates the validators of a request, If-None-Match taking precedence over If-Modified-Since.
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+    if inm := r.Header.Get("If-None-Match"); inm != "" {
+        for _, tag := range strings.Split(inm, ",") {
+            // A GET is revalidated with the weak comparison.
+            tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+
+            if tag == "*" || tag == etag {
+                return true
+            }
+        }
+
+        return false
+    }
+
+    ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+
+    return err == nil && !lastModified.After(ims)
+}
+
+// contentETag derives a strong ETag from the content of a response, leaving out when it was cached or requested.
+func contentETag(content interface{}) string {
+    b, _ := json.Marshal(content)
+    hash := sha256.Sum256(b)
+
+    return fmt.Sprintf(`"%x"`, hash[:16])
+}
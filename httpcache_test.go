@@ -0,0 +1,116 @@
+package main
+
+import (
+    "fmt"
+    "time"
+    "testing"
+    "net/http"
+    "net/http/httptest"
+)
+
+// -- TESTS --
+
+func TestHTTPCaching(t *testing.T) {
+    cachedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+    etag := contentETag([]interface{}{&Language{Name: "fake"}, &Code{Contents: "print('Hello World')"}})
+
+    var httpCachingTestCases = []httpCachingTestCase{
+        {
+            testName:   "A request without validators should be answered in full",
+            headers:    map[string]string{},
+            status:     http.StatusOK,
+        },
+        {
+            testName:   "A request with the current ETag should not be modified",
+            headers:    map[string]string{"If-None-Match": `"outdated", ` + etag},
+            status:     http.StatusNotModified,
+        },
+        {
+            testName:   "A request with an outdated ETag should be answered in full",
+            headers:    map[string]string{"If-None-Match": `"outdated"`},
+            status:     http.StatusOK,
+        },
+        {
+            testName:   "A request for a response unchanged since it was cached should not be modified",
+            headers:    map[string]string{"If-Modified-Since": cachedAt.UTC().Format(http.TimeFormat)},
+            status:     http.StatusNotModified,
+        },
+        {
+            testName:   "A request for a response changed since it was cached should be answered in full",
+            headers:    map[string]string{"If-Modified-Since": cachedAt.Add(-time.Minute).UTC().Format(http.TimeFormat)},
+            status:     http.StatusOK,
+        },
+        {
+            testName:   "If-None-Match should take precedence over If-Modified-Since",
+            headers:    map[string]string{
+                "If-None-Match": `"outdated"`,
+                "If-Modified-Since": cachedAt.UTC().Format(http.TimeFormat),
+            },
+            status:     http.StatusOK,
+        },
+    }
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(string) Source {
+        return &fakeSource{"fake": "print('Hello World')"}
+    }
+
+    for _, c := range httpCachingTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            cacheSet(languageKey("fake"), LanguageResponse{
+                Code: &Code{Contents: "print('Hello World')"},
+                Language: &Language{Name: "fake"},
+                CachedAt: cachedAt,
+            })
+
+            assertHTTPCaching(t, c.headers, c.status, etag, cachedAt)
+        })
+    }
+}
+
+// --- ASSERTS ---
+
+func assertHTTPCaching(t *testing.T, headers map[string]string, status int, etag string, cachedAt time.Time) {
+    req := httptest.NewRequest("GET", "http://localhost:8080/api/language/fake", nil)
+    for name, value := range headers {
+        req.Header.Set(name, value)
+    }
+    w := httptest.NewRecorder()
+    getLanguage(w, req)
+
+    resp := w.Result()
+
+    if resp.StatusCode != status {
+        t.Errorf("Status code (%d) expected to be %d", resp.StatusCode, status)
+        return
+    }
+
+    if resp.Header.Get("ETag") != etag {
+        t.Errorf("ETag (%v) expected to be (%v)", resp.Header.Get("ETag"), etag)
+    }
+
+    if lastModified := cachedAt.UTC().Format(http.TimeFormat); resp.Header.Get("Last-Modified") != lastModified {
+        t.Errorf("Last-Modified (%v) expected to be (%v)", resp.Header.Get("Last-Modified"), lastModified)
+    }
+
+    // What is left of the TTL when the response was written, to the second.
+    var maxAge int
+    fmt.Sscanf(resp.Header.Get("Cache-Control"), "public, max-age=%d", &maxAge)
+
+    if left := int((languageTTL() - time.Since(cachedAt)).Seconds()); maxAge < left - 1 || maxAge > left + 1 {
+        t.Errorf("Cache-Control (%v) expected to be (public, max-age=%d)", resp.Header.Get("Cache-Control"), left)
+    }
+
+    if status == http.StatusNotModified && w.Body.Len() != 0 {
+        t.Errorf("Response not modified expected to have no body, not (%s)", w.Body.String())
+    }
+}
+
+// --- STRUCTS ---
+
+type httpCachingTestCase struct {
+    testName    string
+    headers     map[string]string
+    status      int
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+    "sync"
+    "time"
+    "container/list"
+)
+
+// LRUCache is an in-process cache bounded by its number of entries and their total size, evicting the least
+// recently used entries first. Either bound is ignored when zero.
+type LRUCache struct {
+    MaxEntries      int
+    MaxBytes        int
+    LifeWindow      time.Duration
+
+    mu              sync.Mutex
+    ll              *list.List
+    items           map[string]*list.Element
+    size            int
+}
+
+type lruEntry struct {
+    key             string
+    value           []byte
+    storedAt        time.Time
+}
+
+func NewLRUCache(maxEntries int, maxBytes int, lifeWindow time.Duration) *LRUCache {
+    return &LRUCache{
+        MaxEntries: maxEntries,
+        MaxBytes: maxBytes,
+        LifeWindow: lifeWindow,
+        ll: list.New(),
+        items: make(map[string]*list.Element),
+    }
+}
+
+func (c *LRUCache) Get(key string) ([]byte, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    el, ok := c.items[key]
+
+    if !ok {
+        return nil, ErrEntryNotFound
+    }
+
+    e := el.Value.(*lruEntry)
+
+    if c.LifeWindow > 0 && time.Since(e.storedAt) > c.LifeWindow {
+        c.remove(el)
+        return nil, ErrEntryNotFound
+    }
+
+    c.ll.MoveToFront(el)
+
+    return e.value, nil
+}
+
+func (c *LRUCache) Set(key string, entry []byte) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if el, ok := c.items[key]; ok {
+        c.remove(el)
+    }
+
+    c.items[key] = c.ll.PushFront(&lruEntry{
+        key: key,
+        value: entry,
+        storedAt: time.Now(),
+    })
+    c.size += len(entry)
+
+    for c.ll.Len() > 1 && ((c.MaxEntries > 0 && c.ll.Len() > c.MaxEntries) || (c.MaxBytes > 0 && c.size > c.MaxBytes)) {
+        c.remove(c.ll.Back())
+    }
+
+    return nil
+}
+
+func (c *LRUCache) Delete(key string) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    el, ok := c.items[key]
+
+    if !ok {
+        return ErrEntryNotFound
+    }
+
+    c.remove(el)
+
+    return nil
+}
+
+func (c *LRUCache) Reset() error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    c.ll.Init()
+    c.items = make(map[string]*list.Element)
+    c.size = 0
+
+    return nil
+}
+
+func (c *LRUCache) Len() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    return c.ll.Len()
+}
+
+// Range calls fn with every live entry, from the least to the most recently used.
+func (c *LRUCache) Range(fn func(key string, entry []byte)) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for el := c.ll.Back(); el != nil; el = el.Prev() {
+        if e := el.Value.(*lruEntry); c.LifeWindow <= 0 || time.Since(e.storedAt) <= c.LifeWindow {
+            fn(e.key, e.value)
+        }
+    }
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+    e := c.ll.Remove(el).(*lruEntry)
+    delete(c.items, e.key)
+    c.size -= len(e.value)
+}
@@ -1,22 +1,27 @@
 package main
 
 import (
+    "os"
     "fmt"
+    "log"
+    "math"
     "time"
-    "bytes"
+    "errors"
+    "expvar"
     "regexp"
     "context"
     "strings"
+    "strconv"
+    "syscall"
     "net/url"
     "net/http"
-    "encoding/gob"
+    "os/signal"
     "encoding/json"
     "path/filepath"
 
     "golang.org/x/oauth2"
     "github.com/spf13/viper"
     "github.com/gorilla/mux"
-	"github.com/allegro/bigcache/v3"
     "github.com/google/go-github/v49/github"
 )
 
@@ -34,12 +39,18 @@ type LanguageResponse struct {
     Language        *Language   `"json:language"`
     CachedAt        time.Time   `"json:cached_at"`
     RequestedAt     time.Time   `"json:requested_at"`
+    Stale           bool
+    Validators      Validators  `json:"-"`
+    // NotFound marks a cached lookup of a language which does not exist.
+    NotFound        bool        `json:"-"`
 }
 
 type LanguagesResponse struct {
     Languages       []*Language `"json:languages"`
     CachedAt        time.Time   `"json:cached_at"`
     RequestedAt     time.Time   `"json:requested_at"`
+    Stale           bool
+    Validators      Validators  `json:"-"`
 }
 
 // Stolen from: https://github.com/google/go-github/blob/838d2238a6da019b49b571e8d8ebc5a6b12f8844/github/github.go#L863
@@ -50,13 +61,18 @@ type ErrorResponse struct {
 }
 
 func (r *ErrorResponse) Error() string {
+    // Errors of the sources are only given a request by writeError.
+    if r.Request == nil {
+        return fmt.Sprintf("%d %v", r.StatusCode, r.Message)
+    }
+
 	return fmt.Sprintf("%v %v: %d %v",
 		r.Request.Method, r.Request.URL,
 		r.StatusCode, r.Message)
 }
 
 var ctx context.Context
-var cache *bigcache.BigCache
+var cache Cache
 
 func home(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "application/json")
@@ -70,55 +86,48 @@ func getLanguages(w http.ResponseWriter, r *http.Request) {
 
     w.Header().Set("Content-Type", "application/json")
 
-    if err := cacheGet("languages", &res); err == nil {
-        res.RequestedAt = time.Now()
-        json.NewEncoder(w).Encode(res)
-        return
-    }
-
-    client := authorize(r.Header.Get("Authorization"))
+    auth := r.Header.Get("Authorization")
+    key := scopedKey(auth, "languages")
+    lookup := cacheGet(key, &res)
+    cached := lookup == nil
+
+    switch {
+    case cached && isFresh(res.CachedAt, languagesTTL()):
+        cacheStats.record("languages", cacheHit)
+    case cached && isRevalidatable(res.CachedAt, languagesTTL()):
+        cacheStats.record("languages", cacheStale)
+
+        // Serve the expired entry right away, refreshing it for the next callers.
+        expired := res
+        revalidate(key, func() (interface{}, error) {
+            return refreshLanguages(auth, expired)
+        })
+    default:
+        cacheStats.record("languages", missOutcome(lookup))
 
-    // Get the README object.
-    readme, _, err := client.Repositories.GetReadme(
-        ctx,
-        viper.GetString("repository.user"),
-        viper.GetString("repository.name"),
-        nil,
-    )
+        fresh, err := flights.Do(r.Context(), key, func() (interface{}, error) {
+            return refreshLanguages(auth, res)
+        })
 
-    if err != nil {
-        if err, ok := err.(*github.ErrorResponse); ok {
-            w.WriteHeader(err.Response.StatusCode)
+        if r.Context().Err() != nil {
+            // The caller is gone, the fetch still fills the cache for the others.
+            return
+        } else if err != nil && cached && upstreamUnavailable(err) {
+            res.Stale = true
+            writeStale(w)
+        } else if err != nil {
+            writeError(w, r, err)
+            return
         } else {
-            w.WriteHeader(http.StatusInternalServerError)
+            res = fresh.(LanguagesResponse)
         }
-        json.NewEncoder(w).Encode(err.Error())
-        return
     }
 
-    // Get the README contents.
-    s, err := readme.GetContent()
-
-    if err != nil {
-        if err, ok := err.(*github.ErrorResponse); ok {
-            w.WriteHeader(err.Response.StatusCode)
-        } else {
-            w.WriteHeader(http.StatusInternalServerError)
-        }
-        json.NewEncoder(w).Encode(err.Error())
+    if writeCacheHeaders(w, r, res.Languages, res.CachedAt, languagesTTL()) {
         return
     }
 
-    languages := findLanguages(s)
-
-    res = LanguagesResponse{
-        Languages: languages,
-        CachedAt: time.Now(),
-        RequestedAt: time.Now(),
-    }
-
-    cacheSet("languages", res)
-
+    res.RequestedAt = time.Now()
     json.NewEncoder(w).Encode(res)
 }
 
@@ -129,129 +138,388 @@ func getLanguage(w http.ResponseWriter, r *http.Request) {
 
     l := strings.TrimPrefix(r.URL.Path, "/api/language/")
 
-    if err := cacheGet("language-" + l, &res); err == nil {
-        res.RequestedAt = time.Now()
-        json.NewEncoder(w).Encode(res)
-        return
-    }
+    auth := r.Header.Get("Authorization")
+    key := scopedKey(auth, languageKey(l))
+    lookup := cacheGet(key, &res)
+    cached := lookup == nil
 
-    initial := strings.ToLower(l[0:1])
-    client := authorize(r.Header.Get("Authorization"))
+    if cached && res.NotFound {
+        if time.Since(res.CachedAt) < cacheNegativeTTL() {
+            cacheStats.record("language", cacheHit)
+            writeError(w, r, notFound())
+            return
+        }
 
-    if !regexp.MustCompile("^[a-z]$").MatchString(initial) {
-        initial = "#"
+        // An expired negative entry is looked up again.
+        cached, res = false, LanguageResponse{}
     }
 
-    _, dir, _, err := client.Repositories.GetContents(
-        ctx,
-        viper.GetString("repository.user"),
-        viper.GetString("repository.name"),
-        initial,
-        nil,
-    )
+    switch {
+    case cached && isFresh(res.CachedAt, languageTTL()):
+        cacheStats.record("language", cacheHit)
+    case cached && isRevalidatable(res.CachedAt, languageTTL()):
+        cacheStats.record("language", cacheStale)
 
-    if err != nil {
-        if err, ok := err.(*github.ErrorResponse); ok {
-            w.WriteHeader(err.Response.StatusCode)
-        } else {
-            w.WriteHeader(http.StatusInternalServerError)
-        }
-        json.NewEncoder(w).Encode(err.Error())
-        return
-    }
+        // Serve the expired entry right away, refreshing it for the next callers.
+        expired := res
+        revalidate(key, func() (interface{}, error) {
+            return refreshLanguage(auth, l, expired)
+        })
+    default:
+        cacheStats.record("language", missOutcome(lookup))
 
-    language, err := findLanguage(dir, l)
+        fresh, err := flights.Do(r.Context(), key, func() (interface{}, error) {
+            return refreshLanguage(auth, l, res)
+        })
 
-    if err != nil {
-        if err, ok := err.(*ErrorResponse); ok {
-            err.Request = r
-            w.WriteHeader(err.StatusCode)
+        if r.Context().Err() != nil {
+            // The caller is gone, the fetch still fills the cache for the others.
+            return
+        } else if err != nil && cached && upstreamUnavailable(err) {
+            res.Stale = true
+            writeStale(w)
+        } else if err != nil {
+            writeError(w, r, err)
+            return
         } else {
-            w.WriteHeader(http.StatusInternalServerError)
+            res = fresh.(LanguageResponse)
         }
-        json.NewEncoder(w).Encode(err.Error())
+    }
+
+    if writeCacheHeaders(w, r, []interface{}{res.Language, res.Code}, res.CachedAt, languageTTL()) {
         return
     }
 
-    file, _, _, err := client.Repositories.GetContents(
-        ctx,
-        viper.GetString("repository.user"),
-        viper.GetString("repository.name"),
-        initial + "/" + language.Name + language.Extension,
-        nil,
-    )
+    res.RequestedAt = time.Now()
+    json.NewEncoder(w).Encode(res)
+}
 
-    if err != nil {
-        if err, ok := err.(*github.ErrorResponse); ok {
-            w.WriteHeader(err.Response.StatusCode)
-        } else {
-            w.WriteHeader(http.StatusInternalServerError)
-        }
-        json.NewEncoder(w).Encode(err.Error())
-        return
+// refreshLanguages fetches the catalog and caches it. An expired entry is revalidated with its validators, a
+// missing one (the zero value) is fetched without.
+func refreshLanguages(auth string, cached LanguagesResponse) (LanguagesResponse, error) {
+    src := newSource(auth)
+    languages, v, err := fetchLanguages(ctx, src, cached.Validators)
+
+    if err == errNotModified {
+        languages = cached.Languages
+    } else if err != nil {
+        return cached, err
     }
 
-    s, err := file.GetContent()
+    res := LanguagesResponse{
+        Languages: languages,
+        CachedAt: time.Now(),
+        Validators: v,
+    }
 
-    if err != nil {
-        if err, ok := err.(*github.ErrorResponse); ok {
-            w.WriteHeader(err.Response.StatusCode)
-        } else {
-            w.WriteHeader(http.StatusInternalServerError)
+    cacheSet(scopedKey(auth, "languages"), res)
+
+    return res, nil
+}
+
+// refreshLanguage fetches the code of a language and caches it. An expired entry is revalidated with its
+// validators, a missing one (the zero value) is looked up and fetched without.
+func refreshLanguage(auth string, l string, cached LanguageResponse) (LanguageResponse, error) {
+    src := newSource(auth)
+    language := cached.Language
+
+    if language == nil {
+        // A language missing from a complete catalog is not looked up upstream.
+        if hasCompleteCatalog(src) && notInCatalog(auth, l) {
+            return cached, notFound()
+        }
+
+        var err error
+        if language, err = src.Language(ctx, l); err != nil {
+            return cached, cacheNotFound(auth, l, err)
         }
-        json.NewEncoder(w).Encode(err.Error())
-        return
     }
 
-    code := &Code{
-        Contents: s,
+    code, v, err := fetchCode(ctx, src, language, cached.Validators)
+
+    if err == errNotModified {
+        code = cached.Code
+    } else if err != nil {
+        return cached, cacheNotFound(auth, l, err)
     }
 
-    res = LanguageResponse{
+    res := LanguageResponse{
         Code: code,
         Language: language,
         CachedAt: time.Now(),
-        RequestedAt: time.Now(),
+        Validators: v,
     }
 
-    cacheSet("language-" + l, res)
+    cacheSet(scopedKey(auth, languageKey(l)), res)
 
-    json.NewEncoder(w).Encode(res)
+    return res, nil
+}
+
+// notInCatalog reports whether a language is missing from the fresh cached catalog, if there is one.
+func notInCatalog(auth string, l string) bool {
+    var catalog LanguagesResponse
+
+    if cacheGet(scopedKey(auth, "languages"), &catalog) != nil || !isFresh(catalog.CachedAt, languagesTTL()) {
+        return false
+    }
+
+    for _, language := range catalog.Languages {
+        if strings.EqualFold(language.Name, l) {
+            return false
+        }
+    }
+
+    return true
+}
+
+// cacheNotFound caches a negative entry for a language, when the error looking it up is a 404.
+func cacheNotFound(auth string, l string, err error) error {
+    if isNotFound(err) {
+        cacheSet(scopedKey(auth, languageKey(l)), LanguageResponse{
+            CachedAt: time.Now(),
+            NotFound: true,
+        })
+    }
+
+    return err
 }
 
 func main() {
     router := mux.NewRouter()
     ctx = context.Background()
-    cache, _ = bigcache.New(ctx, bigcache.DefaultConfig(24 * time.Hour))
 
     loadConfigs([]string {
         "env",
     })
 
+    if err := checkCacheConfig(); err != nil {
+        log.Fatal(err)
+    }
+
+    // Entries are kept past their TTL, so they can be revalidated rather than fetched again.
+    var err error
+    if cache, err = newCache(); err != nil {
+        log.Fatal(err)
+    }
+
+    // Restore the entries cached before the last shutdown, so a restart does not start cold.
+    if p := snapshotPath(); p != "" {
+        n, err := restoreSnapshot(cache, p)
+
+        if err != nil {
+            log.Printf("Restoring the cache snapshot failed: %v", err)
+        }
+
+        log.Printf("Restored %d cache entries from %v", n, p)
+        go snapshotEvery(p, viper.GetDuration("cache.snapshot.interval"))
+    }
+
+    publishCacheStats()
+
+    breaker = newCircuitBreaker()
+
+    if err := checkVisibility(); err != nil {
+        log.Fatal(err)
+    }
+
+    if err := loadGitHubURLs(); err != nil {
+        log.Fatal(err)
+    }
+
+    if err := loadAppCredentials(); err != nil {
+        log.Fatal(err)
+    }
+
+    loadTokenPool()
+
+    if newSource, err = sourceFactory(viper.GetString("source.type")); err != nil {
+        log.Fatal(err)
+    }
+
+    if viper.GetBool("sync.enabled") {
+        syncer = newSyncer()
+        syncer.Start()
+    }
+
     router.HandleFunc("/api", home).Methods(http.MethodGet)
     router.HandleFunc("/api/languages", getLanguages).Methods(http.MethodGet)
     router.HandleFunc("/api/language/{language}", getLanguage).Methods(http.MethodGet)
+    router.HandleFunc("/api/sync", getSync).Methods(http.MethodGet)
+    router.HandleFunc("/api/ratelimit", getRateLimit).Methods(http.MethodGet)
+    router.HandleFunc("/api/webhooks/github", postGitHubWebhook).Methods(http.MethodPost)
+    router.HandleFunc("/api/admin/cache", adminOnly(getCache)).Methods(http.MethodGet)
+    router.HandleFunc("/api/admin/cache", adminOnly(deleteCache)).Methods(http.MethodDelete)
+    router.HandleFunc("/api/admin/cache/stats", adminOnly(getCacheStats)).Methods(http.MethodGet)
+    router.HandleFunc("/api/admin/cache/warm", adminOnly(postCacheWarm)).Methods(http.MethodPost)
+    router.HandleFunc("/api/admin/cache/{key:.+}", adminOnly(deleteCacheKey)).Methods(http.MethodDelete)
+    router.HandleFunc("/debug/vars", adminOnly(expvar.Handler().ServeHTTP)).Methods(http.MethodGet)
+
+    server := &http.Server{
+        Addr: ":" + viper.GetString("server.port"),
+        Handler: router,
+    }
 
-    http.ListenAndServe(
-        ":" + viper.GetString("server.port"),
-        router,
-    )
+    go func() {
+        if err := server.ListenAndServe(); err != http.ErrServerClosed {
+            log.Fatal(err)
+        }
+    }()
+
+    // Finish the requests in flight and snapshot the cache before exiting.
+    stop, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+    defer cancel()
+    <-stop.Done()
+
+    shutdown, cancelShutdown := context.WithTimeout(ctx, 10 * time.Second)
+    defer cancelShutdown()
+    server.Shutdown(shutdown)
+
+    if p := snapshotPath(); p != "" {
+        if err := saveSnapshot(cache, p); err != nil {
+            log.Printf("Saving the cache snapshot failed: %v", err)
+        }
+    }
 }
 
 // --- HELPERS ---
 
 func authorize(s string) *github.Client {
-    if s == "" {
-        return github.NewClient(nil)
+    t := callerToken(s)
+
+    // Anonymous callers, and callers whose tokens do not take precedence, use the service's credentials.
+    if t == "" || !callerTokensOverride() {
+        return newGitHubClient(serviceHTTPClient())
     }
 
-    t := strings.Replace(s, "Bearer", "", 1)
     ts := oauth2.StaticTokenSource(
         &oauth2.Token{AccessToken: t},
     )
-    tc := oauth2.NewClient(ctx, ts)
+    tc := callerClient(ts)
+
+    return newGitHubClient(tc)
+}
+
+// callerToken returns the token of an Authorization header, if there is one.
+func callerToken(s string) string {
+    return strings.TrimSpace(strings.Replace(s, "Bearer", "", 1))
+}
+
+// writeStale warns that an expired response is served because GitHub is unavailable.
+func writeStale(w http.ResponseWriter) {
+    w.Header().Set("Warning", `110 - "Response is Stale"`)
+}
+
+// writeError responds with the status code carried by an upstream or lookup error, if any.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+    // Ask callers to come back once the quota resets, rather than passing GitHub's 403 through.
+    if wait, ok := rateLimited(err); ok {
+        seconds := int(math.Ceil(wait.Seconds()))
+
+        if seconds < 1 {
+            seconds = 1
+        }
+
+        err = &ErrorResponse{
+            Request: r,
+            StatusCode: http.StatusServiceUnavailable,
+            Message: "GitHub rate limit exceeded",
+        }
+
+        w.Header().Set("Retry-After", strconv.Itoa(seconds))
+    }
+
+    switch e := err.(type) {
+    case *github.ErrorResponse:
+        w.WriteHeader(e.Response.StatusCode)
+    case *ErrorResponse:
+        // The error may be shared by every caller of a coalesced fetch, so it is described with a copy.
+        described := *e
+        described.Request = r
+        err = &described
+
+        w.WriteHeader(e.StatusCode)
+    default:
+        w.WriteHeader(http.StatusInternalServerError)
+    }
+
+    json.NewEncoder(w).Encode(err.Error())
+}
+
+// cacheTTL is how long a cached response is served before it is revalidated upstream.
+func cacheTTL() time.Duration {
+    if ttl := viper.GetDuration("cache.ttl"); ttl > 0 {
+        return ttl
+    }
+
+    return 24 * time.Hour
+}
+
+// cacheRetention is how long a cached response is kept, fresh or not.
+func cacheRetention() time.Duration {
+    if retention := viper.GetDuration("cache.retention"); retention > 0 {
+        return retention
+    }
 
-    return github.NewClient(tc)
+    return 7 * 24 * time.Hour
+}
+
+// languagesTTL is how long the cached catalog is served before it is revalidated upstream.
+func languagesTTL() time.Duration {
+    return routeTTL("cache.languages_ttl")
+}
+
+// languageTTL is how long the cached code of a language is served before it is revalidated upstream.
+func languageTTL() time.Duration {
+    return routeTTL("cache.language_ttl")
+}
+
+// routeTTL is the TTL of a route, or cache.ttl when it has none of its own.
+func routeTTL(key string) time.Duration {
+    if ttl := viper.GetDuration(key); ttl > 0 {
+        return ttl
+    }
+
+    return cacheTTL()
+}
+
+// cacheNegativeTTL is how long a language known not to exist is remembered.
+func cacheNegativeTTL() time.Duration {
+    if ttl := viper.GetDuration("cache.negative_ttl"); ttl > 0 {
+        return ttl
+    }
+
+    return time.Hour
+}
+
+// cacheHardTTL is how long an expired response is still served while it is revalidated in the background, given its TTL.
+// Past it, callers wait for the response to be revalidated.
+func cacheHardTTL(ttl time.Duration) time.Duration {
+    if hard := viper.GetDuration("cache.hard_ttl"); hard > ttl {
+        return hard
+    }
+
+    if viper.IsSet("cache.hard_ttl") {
+        return ttl
+    }
+
+    return 2 * ttl
+}
+
+func isFresh(cachedAt time.Time, ttl time.Duration) bool {
+    return time.Since(cachedAt) < ttl
+}
+
+func isRevalidatable(cachedAt time.Time, ttl time.Duration) bool {
+    return time.Since(cachedAt) < cacheHardTTL(ttl)
+}
+
+// revalidate refreshes a key in the background, unless it is already being refreshed.
+func revalidate(key string, refresh func() (interface{}, error)) {
+    go func() {
+        if _, err := flights.Do(ctx, key, refresh); err != nil {
+            log.Printf("Revalidating %v failed: %v", key, err)
+        }
+    }()
 }
 
 func cacheGet(key string, res interface{}) error {
@@ -261,12 +529,16 @@ func cacheGet(key string, res interface{}) error {
         return err
     }
 
-    buffer := bytes.NewBuffer(entry)
-    dec := gob.NewDecoder(buffer)
-    err = dec.Decode(res)
+    err = decodeEntry(entry, res)
+
+    if err == errEntryLegacy {
+        // Entries written before envelopes are migrated to one.
+        cacheSet(key, res)
+        err = nil
+    }
 
     if err != nil {
-        // If the cache entry throws an error when decoding, delete it and fetch it again.
+        // If the cache entry is outdated or throws an error when decoding, delete it and fetch it again.
         cache.Delete(key)
         return err
     }
@@ -274,16 +546,19 @@ func cacheGet(key string, res interface{}) error {
     return nil
 }
 
+// languageKey is the cache key of a language, whatever the case it was requested in.
+func languageKey(l string) string {
+    return "language-" + strings.ToLower(l)
+}
+
 func cacheSet(key string, value interface{}) error {
-    buffer := bytes.NewBuffer([]byte{})
-    enc := gob.NewEncoder(buffer)
-    err := enc.Encode(value)
+    entry, err := encodeEntry(value)
 
     if err != nil {
         return err
     }
 
-    return cache.Set(key, buffer.Bytes())
+    return cache.Set(key, entry)
 }
 
 func findLanguage(rcs []*github.RepositoryContent, l string) (*Language, error) {
@@ -300,11 +575,7 @@ func findLanguage(rcs []*github.RepositoryContent, l string) (*Language, error)
         }
     }
 
-    return nil, &ErrorResponse{
-        Request: nil,
-		StatusCode: http.StatusNotFound,
-		Message: "Not Found",
-	}
+    return nil, notFound()
 }
 
 func findLanguages(s string) (languages []*Language) {
@@ -331,6 +602,26 @@ func findLanguages(s string) (languages []*Language) {
     return
 }
 
+// notFound is the error returned when a language is not in the catalog.
+func notFound() *ErrorResponse {
+    return &ErrorResponse{
+        Request: nil,
+		StatusCode: http.StatusNotFound,
+		Message: "Not Found",
+	}
+}
+
+// isNotFound reports whether an error is a 404, from GitHub or from a source.
+func isNotFound(err error) bool {
+    var e *github.ErrorResponse
+    if errors.As(err, &e) {
+        return e.Response.StatusCode == http.StatusNotFound
+    }
+
+    var r *ErrorResponse
+    return errors.As(err, &r) && r.StatusCode == http.StatusNotFound
+}
+
 func isLanguage(rc *github.RepositoryContent, l string) bool {
     name := rc.GetName()
     ext := filepath.Ext(name)
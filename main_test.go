@@ -9,11 +9,13 @@ import (
     "strings"
     "testing"
     "net/http"
+    "sync/atomic"
     "encoding/json"
     "net/http/httptest"
 
     "golang.org/x/oauth2"
     "github.com/spf13/viper"
+    "github.com/allegro/bigcache/v3"
     "github.com/google/go-github/v49/github"
 )
 
@@ -21,6 +23,7 @@ var client *github.Client
 
 func setup() (err error) {
     ctx = context.Background()
+    cache, _ = bigcache.New(ctx, bigcache.DefaultConfig(24 * time.Hour))
 
     // Load configuration files.
     viper.AddConfigPath("config")
@@ -419,6 +422,161 @@ func TestGetLanguage(t *testing.T) {
     }
 }
 
+func TestGetLanguageFromSource(t *testing.T) {
+    var getLanguageFromSourceTestCases = []sourceRouteTestCase{
+        {
+            testName:   "A language in the source should be returned with its code",
+            path:       "/api/language/fake",
+            status:     http.StatusOK,
+            expected:   "print('Hello World')",
+        },
+        {
+            testName:   "A language missing from the source should not be found",
+            path:       "/api/language/missing",
+            status:     http.StatusNotFound,
+            expected:   "",
+        },
+    }
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(string) Source {
+        return &fakeSource{
+            "fake": "print('Hello World')",
+        }
+    }
+
+    for _, c := range getLanguageFromSourceTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            assertSourceRoute(t, getLanguage, c.path, c.status, c.expected)
+        })
+    }
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+    var staleWhileRevalidateTestCases = []revalidateTestCase{
+        {
+            testName:   "A fresh language should be served from the cache",
+            age:        0,
+            served:     "old",
+            expected:   "old",
+        },
+        {
+            testName:   "An expired language should be served from the cache and refreshed in the background",
+            age:        languageTTL() + time.Minute,
+            served:     "old",
+            expected:   "new",
+        },
+        {
+            testName:   "A language past its hard TTL should be refreshed before being served",
+            age:        cacheHardTTL(languageTTL()) + time.Minute,
+            served:     "new",
+            expected:   "new",
+        },
+    }
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(string) Source {
+        return &fakeSource{"fake": "new"}
+    }
+
+    for _, c := range staleWhileRevalidateTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            cacheSet(languageKey("fake"), LanguageResponse{
+                Code: &Code{Contents: "old"},
+                Language: &Language{Name: "fake"},
+                CachedAt: time.Now().Add(-c.age),
+            })
+
+            assertSourceRoute(t, getLanguage, "/api/language/fake", http.StatusOK, c.served)
+            assertRevalidated(t, languageKey("fake"), c.expected)
+        })
+    }
+}
+
+func TestNegativeCache(t *testing.T) {
+    var negativeCacheTestCases = []negativeCacheTestCase{
+        {
+            testName:   "A missing language should only be looked up once",
+            catalog:    false,
+            complete:   false,
+            age:        0,
+            expected:   1,
+        },
+        {
+            testName:   "A missing language past its negative TTL should be looked up again",
+            catalog:    false,
+            complete:   false,
+            age:        cacheNegativeTTL() + time.Minute,
+            expected:   2,
+        },
+        {
+            testName:   "A language missing from a cached complete catalog should not be looked up",
+            catalog:    true,
+            complete:   true,
+            age:        0,
+            expected:   0,
+        },
+        {
+            testName:   "A language missing from a cached README's catalog should still be looked up once",
+            catalog:    true,
+            complete:   false,
+            age:        0,
+            expected:   1,
+        },
+    }
+
+    src := &countingSource{Source: &fakeSource{"fake": "print('Hello World')"}}
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+
+    for _, c := range negativeCacheTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            atomic.StoreInt32(&src.lookups, 0)
+
+            newSource = func(string) Source {
+                if c.complete {
+                    return &completeSource{Source: src}
+                }
+
+                return src
+            }
+
+            if c.catalog {
+                cacheSet("languages", LanguagesResponse{
+                    Languages: []*Language{{Name: "fake"}},
+                    CachedAt: time.Now(),
+                })
+            }
+
+            assertSourceRoute(t, getLanguage, "/api/language/missing", http.StatusNotFound, "")
+
+            var res LanguageResponse
+            if cacheGet(languageKey("missing"), &res) == nil {
+                res.CachedAt = res.CachedAt.Add(-c.age)
+                cacheSet(languageKey("missing"), res)
+            }
+
+            assertSourceRoute(t, getLanguage, "/api/language/missing", http.StatusNotFound, "")
+
+            if lookups := atomic.LoadInt32(&src.lookups); lookups != c.expected {
+                t.Errorf("Lookups (%d) expected to be (%d)", lookups, c.expected)
+            }
+        })
+    }
+
+    t.Run("A language in the cached catalog should still be found", func(t *testing.T) {
+        cache.Reset()
+        cacheSet("languages", LanguagesResponse{
+            Languages: []*Language{{Name: "Fake"}},
+            CachedAt: time.Now(),
+        })
+
+        assertSourceRoute(t, getLanguage, "/api/language/fake", http.StatusOK, "print('Hello World')")
+    })
+}
+
 // --- ASSERTS ---
 
 func assertAuthorize(t *testing.T, s string, expected bool) {
@@ -518,6 +676,49 @@ func assertGetLanguage(t *testing.T, body []byte, expected interface{}) {
     }
 }
 
+func assertSourceRoute(t *testing.T, fn handler, path string, status int, expected string) {
+    req := httptest.NewRequest("GET", "http://localhost:8080" + path, nil)
+    w := httptest.NewRecorder()
+    fn(w, req)
+
+    resp := w.Result()
+    body, _ := io.ReadAll(resp.Body)
+
+    if resp.StatusCode != status {
+        t.Errorf("Status code (%d) expected to be %d", resp.StatusCode, status)
+        return
+    }
+
+    if status != http.StatusOK {
+        return
+    }
+
+    raw := &LanguageResponse {}
+    if err := json.Unmarshal(body, &raw); err != nil {
+        t.Errorf("Response body (%s) expected to be marshalled into struct (%#v)", string(body), raw)
+        return
+    }
+
+    if raw.Code.Contents != expected {
+        t.Errorf("Code (%s) expected to be (%s)", raw.Code.Contents, expected)
+    }
+}
+
+// assertRevalidated waits for a background refresh to update the cached code, if one is expected to.
+func assertRevalidated(t *testing.T, key string, expected string) {
+    var res LanguageResponse
+
+    for i := 0; i < 100; i++ {
+        if err := cacheGet(key, &res); err == nil && res.Code.Contents == expected {
+            return
+        }
+
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    t.Errorf("Cached code (%v) expected to be (%v)", res.Code.Contents, expected)
+}
+
 // This is an additional assertion, testing common functionality to all routes.
 func assertRoute(t *testing.T, fn handler, path string) []byte {
     req := httptest.NewRequest("GET", "http://localhost:8080" + path, nil)
@@ -579,3 +780,71 @@ type routeTestCase struct {
     handler     handler
     expected    interface{}
 }
+
+type revalidateTestCase struct {
+    testName    string
+    age         time.Duration
+    served      string
+    expected    string
+}
+
+type negativeCacheTestCase struct {
+    testName    string
+    catalog     bool
+    complete    bool
+    age         time.Duration
+    expected    int32
+}
+
+type sourceRouteTestCase struct {
+    testName    string
+    path        string
+    status      int
+    expected    string
+}
+
+// fakeSource is an in-memory source, mapping language names to their code.
+type fakeSource map[string]string
+
+func (s *fakeSource) Languages(ctx context.Context) (languages []*Language, err error) {
+    for name := range *s {
+        languages = append(languages, &Language{Name: name})
+    }
+
+    return
+}
+
+func (s *fakeSource) Language(ctx context.Context, l string) (*Language, error) {
+    if _, ok := (*s)[l]; !ok {
+        return nil, &ErrorResponse{
+            StatusCode: http.StatusNotFound,
+            Message: "Not Found",
+        }
+    }
+
+    return &Language{Name: l}, nil
+}
+
+func (s *fakeSource) Code(ctx context.Context, language *Language) (*Code, error) {
+    return &Code{Contents: (*s)[language.Name]}, nil
+}
+
+// completeSource is a source whose catalog lists every language.
+type completeSource struct {
+    Source
+}
+
+func (s *completeSource) CompleteCatalog() bool {
+    return true
+}
+
+// countingSource counts the languages looked up in a source.
+type countingSource struct {
+    Source
+    lookups         int32
+}
+
+func (s *countingSource) Language(ctx context.Context, l string) (*Language, error) {
+    atomic.AddInt32(&s.lookups, 1)
+    return s.Source.Language(ctx, l)
+}
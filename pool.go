@@ -0,0 +1,127 @@
+package main
+
+import (
+    "sync"
+    "time"
+    "net/http"
+
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+// TokenPool authenticates each request with whichever of its tokens has the most quota left.
+type TokenPool struct {
+    Base            http.RoundTripper
+
+    mu              sync.Mutex
+    tokens          []*pooledToken
+}
+
+type pooledToken struct {
+    token           string
+    rate            *github.Rate
+}
+
+// tokenPool shares the configured service tokens between requests, if there are several.
+var tokenPool *TokenPool
+
+func loadTokenPool() {
+    if tokens := viper.GetStringSlice("github.tokens"); len(tokens) > 0 {
+        tokenPool = NewTokenPool(tokens, upstreamTransport())
+    }
+}
+
+func NewTokenPool(tokens []string, base http.RoundTripper) *TokenPool {
+    p := &TokenPool{
+        Base: base,
+    }
+
+    for _, t := range tokens {
+        p.tokens = append(p.tokens, &pooledToken{token: t})
+    }
+
+    return p
+}
+
+func (p *TokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+    tried := make(map[*pooledToken]bool)
+
+    for {
+        t := p.pick(tried)
+        tried[t] = true
+
+        r := req.Clone(req.Context())
+        r.Header.Set("Authorization", "Bearer " + t.token)
+
+        resp, err := p.base().RoundTrip(r)
+
+        if err != nil {
+            return nil, err
+        }
+
+        p.record(t, resp)
+
+        // Switch to another token when this one is exhausted, as long as the request can be sent again.
+        exhausted := resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0"
+
+        if !exhausted || len(tried) == len(p.tokens) || (req.Body != nil && req.GetBody == nil) {
+            return resp, nil
+        }
+
+        resp.Body.Close()
+
+        if req.GetBody != nil {
+            if req.Body, err = req.GetBody(); err != nil {
+                return nil, err
+            }
+        }
+    }
+}
+
+// pick returns the untried token with the most quota left. Tokens never used, or past their reset, count as unused.
+func (p *TokenPool) pick(tried map[*pooledToken]bool) *pooledToken {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    var best *pooledToken
+    bestRemaining := -1
+
+    for _, t := range p.tokens {
+        if tried[t] {
+            continue
+        }
+
+        remaining := int(^uint(0) >> 1)
+        if t.rate != nil && time.Now().Before(t.rate.Reset.Time) {
+            remaining = t.rate.Remaining
+        }
+
+        if remaining > bestRemaining {
+            best = t
+            bestRemaining = remaining
+        }
+    }
+
+    return best
+}
+
+// record keeps the rate limit of a token, from the same headers go-github reads into Response.Rate.
+func (p *TokenPool) record(t *pooledToken, resp *http.Response) {
+    if resp.Header.Get("X-RateLimit-Remaining") == "" {
+        return
+    }
+
+    rate := parseRate(resp.Header)
+
+    p.mu.Lock()
+    t.rate = &rate
+    p.mu.Unlock()
+}
+
+func (p *TokenPool) base() http.RoundTripper {
+    if p.Base != nil {
+        return p.Base
+    }
+
+    return http.DefaultTransport
+}
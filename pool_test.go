@@ -0,0 +1,86 @@
+package main
+
+import (
+    "fmt"
+    "time"
+    "strings"
+    "testing"
+    "net/http"
+    "net/http/httptest"
+)
+
+// -- TESTS --
+
+func TestTokenPool(t *testing.T) {
+    var tokenPoolTestCases = []tokenPoolTestCase{
+        {
+            testName:   "Every token should be tried before their quota is known",
+            remaining:  map[string]int{"a": 10, "b": 100},
+            expected:   []string{"a", "b"},
+        },
+        {
+            testName:   "The token with the most quota left should be used",
+            remaining:  map[string]int{"a": 10, "b": 100},
+            expected:   []string{"a", "b", "b", "b"},
+        },
+        {
+            testName:   "An exhausted token should be switched for another",
+            remaining:  map[string]int{"a": 1, "b": 1},
+            expected:   []string{"a", "b", "a", "b"},
+        },
+    }
+
+    for _, c := range tokenPoolTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            var used []string
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+                used = append(used, token)
+
+                w.Header().Set("X-RateLimit-Limit", "5000")
+                w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+
+                if c.remaining[token] == 0 {
+                    w.Header().Set("X-RateLimit-Remaining", "0")
+                    w.WriteHeader(http.StatusForbidden)
+                    return
+                }
+
+                c.remaining[token]--
+                w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(c.remaining[token]))
+            }))
+            defer server.Close()
+
+            assertTokenPool(t, NewTokenPool([]string{"a", "b"}, nil), server.URL, len(c.expected), &used, c.expected)
+        })
+    }
+}
+
+// --- ASSERTS ---
+
+// assertTokenPool sends requests until the expected number of tokens have been used.
+func assertTokenPool(t *testing.T, p *TokenPool, url string, n int, used *[]string, expected []string) {
+    client := &http.Client{Transport: p}
+
+    for len(*used) < n {
+        resp, err := client.Get(url)
+
+        if err != nil {
+            t.Errorf("Request expected to succeed, but failed (%v)", err)
+            return
+        }
+        resp.Body.Close()
+    }
+
+    if strings.Join(*used, ",") != strings.Join(expected, ",") {
+        t.Errorf("Tokens used (%v) expected to be (%v)", *used, expected)
+    }
+}
+
+// --- STRUCTS ---
+
+type tokenPoolTestCase struct {
+    testName    string
+    remaining   map[string]int
+    expected    []string
+}
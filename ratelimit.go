@@ -0,0 +1,185 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+    "errors"
+    "strconv"
+    "net/http"
+    "crypto/sha256"
+    "encoding/json"
+
+    "github.com/google/go-github/v49/github"
+)
+
+type RateLimit struct {
+    Credential      string      `json:"credential"`
+    Resource        string      `json:"resource"`
+    Limit           int         `json:"limit"`
+    Remaining       int         `json:"remaining"`
+    Reset           time.Time   `json:"reset"`
+}
+
+type RateLimitsResponse struct {
+    RateLimits      []*RateLimit `json:"rate_limits"`
+    RequestedAt     time.Time   `json:"requested_at"`
+}
+
+// RateLimitedError is returned, without calling GitHub, for requests made with a credential known to be out of quota.
+type RateLimitedError struct {
+    Credential      string
+    Reset           time.Time
+}
+
+func (e *RateLimitedError) Error() string {
+    return fmt.Sprintf("rate limit of %v exceeded until %v", e.Credential, e.Reset.Format(time.RFC3339))
+}
+
+// RateLimits records the rate limit GitHub reports for every credential of the service.
+type RateLimits struct {
+    mu              sync.Mutex
+    limits          map[string]*RateLimit
+}
+
+var rateLimits = &RateLimits{}
+
+func getRateLimit(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(RateLimitsResponse{
+        RateLimits: rateLimits.All(),
+        RequestedAt: time.Now(),
+    })
+}
+
+// All returns the last known rate limit of every credential, and every resource it was used for.
+func (l *RateLimits) All() []*RateLimit {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    all := []*RateLimit{}
+    for _, limit := range l.limits {
+        copied := *limit
+        all = append(all, &copied)
+    }
+
+    sort.Slice(all, func(i, j int) bool {
+        return all[i].Credential + all[i].Resource < all[j].Credential + all[j].Resource
+    })
+
+    return all
+}
+
+func (l *RateLimits) record(credential string, h http.Header) {
+    if h.Get("X-RateLimit-Remaining") == "" {
+        return
+    }
+
+    rate := parseRate(h)
+    limit := &RateLimit{
+        Credential: credential,
+        Resource: h.Get("X-RateLimit-Resource"),
+        Limit: rate.Limit,
+        Remaining: rate.Remaining,
+        Reset: rate.Reset.Time,
+    }
+
+    if limit.Resource == "" {
+        limit.Resource = "core"
+    }
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    if l.limits == nil {
+        l.limits = make(map[string]*RateLimit)
+    }
+
+    l.limits[credential + " " + limit.Resource] = limit
+}
+
+// exhausted returns when the quota of a credential resets, if it has run out.
+func (l *RateLimits) exhausted(credential string) (time.Time, bool) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    for _, limit := range l.limits {
+        if limit.Credential == credential && limit.Resource == "core" && limit.Remaining == 0 && time.Now().Before(limit.Reset) {
+            return limit.Reset, true
+        }
+    }
+
+    return time.Time{}, false
+}
+
+// rateLimitTransport records the rate limit of every response, and refuses requests from exhausted credentials.
+// Only the service's credentials go through it.
+type rateLimitTransport struct {
+    Base            http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    credential := credentialLabel(req.Header.Get("Authorization"))
+
+    if reset, ok := rateLimits.exhausted(credential); ok {
+        return nil, &RateLimitedError{
+            Credential: credential,
+            Reset: reset,
+        }
+    }
+
+    resp, err := t.Base.RoundTrip(req)
+
+    if err == nil {
+        rateLimits.record(credential, resp.Header)
+    }
+
+    return resp, err
+}
+
+// credentialLabel identifies a credential by a hash long enough for two credentials never to share it, never
+// revealing it.
+func credentialLabel(authorization string) string {
+    if authorization == "" {
+        return "anonymous"
+    }
+
+    hash := sha256.Sum256([]byte(authorization))
+
+    return fmt.Sprintf("token:%x", hash[:16])
+}
+
+// parseRate reads the rate limit headers, the same way go-github fills Response.Rate.
+func parseRate(h http.Header) github.Rate {
+    var rate github.Rate
+
+    rate.Limit, _ = strconv.Atoi(h.Get("X-RateLimit-Limit"))
+    rate.Remaining, _ = strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+
+    if reset, _ := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); reset != 0 {
+        rate.Reset = github.Timestamp{Time: time.Unix(reset, 0)}
+    }
+
+    return rate
+}
+
+// rateLimited reports how long to wait before retrying, if an error is due to GitHub's rate limits.
+func rateLimited(err error) (time.Duration, bool) {
+    var limited *RateLimitedError
+    var primary *github.RateLimitError
+    var secondary *github.AbuseRateLimitError
+
+    switch {
+    case errors.As(err, &limited):
+        return time.Until(limited.Reset), true
+    case errors.As(err, &primary):
+        return time.Until(primary.Rate.Reset.Time), true
+    case errors.As(err, &secondary) && secondary.RetryAfter != nil:
+        return *secondary.RetryAfter, true
+    case errors.As(err, &secondary):
+        return time.Minute, true
+    }
+
+    return 0, false
+}
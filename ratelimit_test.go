@@ -0,0 +1,189 @@
+package main
+
+import (
+    "io"
+    "fmt"
+    "time"
+    "strconv"
+    "testing"
+    "net/url"
+    "net/http"
+    "encoding/json"
+    "net/http/httptest"
+
+    "golang.org/x/oauth2"
+)
+
+// -- TESTS --
+
+func TestRateLimits(t *testing.T) {
+    var rateLimitsTestCases = []rateLimitTestCase{
+        {
+            testName:   "A response with quota left should be served",
+            remaining:  10,
+            status:     http.StatusOK,
+            requests:   2,
+        },
+        {
+            testName:   "A response out of quota should be reported as unavailable until the reset",
+            remaining:  0,
+            status:     http.StatusServiceUnavailable,
+            requests:   1,
+        },
+    }
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    defer func(l *RateLimits) { rateLimits = l }(rateLimits)
+
+    for _, c := range rateLimitsTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            rateLimits = &RateLimits{}
+
+            requests := 0
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                requests++
+                w.Header().Set("X-RateLimit-Limit", "60")
+                w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(c.remaining))
+                w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+
+                if c.remaining == 0 {
+                    w.WriteHeader(http.StatusForbidden)
+                    w.Write([]byte(`{"message": "API rate limit exceeded"}`))
+                    return
+                }
+
+                json.NewEncoder(w).Encode(testContent("README.md", testRepository["README.md"]))
+            }))
+            defer server.Close()
+
+            newSource = func(string) Source {
+                client := newGitHubClient(nil)
+                client.BaseURL, _ = url.Parse(server.URL + "/")
+
+                return &GitHubSource{Client: client, User: "user", Name: "repo"}
+            }
+
+            // Ask twice, bypassing the cache: an exhausted credential should not reach GitHub again.
+            for i := 0; i < 2; i++ {
+                cache.Reset()
+                assertRateLimited(t, c.status)
+            }
+
+            if requests != c.requests {
+                t.Errorf("Requests to GitHub (%d) expected to be (%d)", requests, c.requests)
+            }
+
+            assertRateLimitReport(t, c.remaining)
+        })
+    }
+}
+
+func TestRateLimitsCredentials(t *testing.T) {
+    var rateLimitsCredentialsTestCases = []rateLimitCredentialTestCase{
+        {
+            testName:   "The quota of a service token should be recorded",
+            client:     tokenClient(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "service"})),
+            expected:   []string{credentialLabel("Bearer service")},
+        },
+        {
+            testName:   "The quota of a caller's token should not be recorded, even refused",
+            client:     callerClient(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "notatoken"})),
+            expected:   []string{},
+        },
+    }
+
+    defer func(l *RateLimits) { rateLimits = l }(rateLimits)
+
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("X-RateLimit-Limit", "60")
+        w.Header().Set("X-RateLimit-Remaining", "59")
+        w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+        w.WriteHeader(http.StatusUnauthorized)
+    }))
+    defer server.Close()
+
+    for _, c := range rateLimitsCredentialsTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            rateLimits = &RateLimits{}
+
+            resp, err := c.client.Get(server.URL)
+            if err != nil {
+                t.Errorf("Request expected to reach GitHub, but failed (%v)", err)
+                return
+            }
+            resp.Body.Close()
+
+            credentials := []string{}
+            for _, limit := range rateLimits.All() {
+                credentials = append(credentials, limit.Credential)
+            }
+
+            if fmt.Sprint(credentials) != fmt.Sprint(c.expected) {
+                t.Errorf("Recorded credentials (%v) expected to be (%v)", credentials, c.expected)
+            }
+        })
+    }
+
+    t.Run("A token should be labelled by 128 bits of its hash", func(t *testing.T) {
+        if label := credentialLabel("Bearer service"); len(label) != len("token:") + 32 {
+            t.Errorf("Label (%v) expected to hold 32 hexadecimal digits", label)
+        }
+    })
+}
+
+// --- ASSERTS ---
+
+func assertRateLimited(t *testing.T, status int) {
+    req := httptest.NewRequest("GET", "http://localhost:8080/api/languages", nil)
+    w := httptest.NewRecorder()
+    getLanguages(w, req)
+
+    resp := w.Result()
+
+    if resp.StatusCode != status {
+        t.Errorf("Status code (%d) expected to be %d", resp.StatusCode, status)
+        return
+    }
+
+    if status != http.StatusServiceUnavailable {
+        return
+    }
+
+    if seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After")); seconds < 3500 || seconds > 3600 {
+        t.Errorf("Retry-After (%v) expected to be about an hour", resp.Header.Get("Retry-After"))
+    }
+}
+
+func assertRateLimitReport(t *testing.T, remaining int) {
+    req := httptest.NewRequest("GET", "http://localhost:8080/api/ratelimit", nil)
+    w := httptest.NewRecorder()
+    getRateLimit(w, req)
+
+    body, _ := io.ReadAll(w.Result().Body)
+    raw := &RateLimitsResponse{}
+
+    if err := json.Unmarshal(body, &raw); err != nil {
+        t.Errorf("Response body (%s) expected to be marshalled into struct (%#v)", string(body), raw)
+        return
+    }
+
+    if len(raw.RateLimits) != 1 || raw.RateLimits[0].Credential != "anonymous" || raw.RateLimits[0].Remaining != remaining {
+        t.Errorf("Rate limits (%s) expected to report %d requests left for anonymous calls", string(body), remaining)
+    }
+}
+
+// --- STRUCTS ---
+
+type rateLimitTestCase struct {
+    testName    string
+    remaining   int
+    status      int
+    requests    int
+}
+
+type rateLimitCredentialTestCase struct {
+    testName    string
+    client      *http.Client
+    expected    []string
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+    "io"
+    "net"
+    "time"
+    "bytes"
+    "errors"
+    "context"
+    "strconv"
+    "strings"
+    "syscall"
+    "net/http"
+    "math/rand"
+
+    "github.com/spf13/viper"
+)
+
+// retryTransport retries requests to GitHub that failed for transient reasons: server errors, dropped
+// connections, timeouts and secondary rate limits. Attempts are spaced by a capped exponential backoff with
+// jitter, or by the delay GitHub asks for in Retry-After.
+type retryTransport struct {
+    Base            http.RoundTripper
+    Attempts        int
+    BaseDelay       time.Duration
+    MaxDelay        time.Duration
+    AttemptTimeout  time.Duration
+}
+
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+    t := &retryTransport{
+        Base: base,
+        Attempts: viper.GetInt("retry.attempts"),
+        BaseDelay: viper.GetDuration("retry.base_delay"),
+        MaxDelay: viper.GetDuration("retry.max_delay"),
+        AttemptTimeout: viper.GetDuration("retry.attempt_timeout"),
+    }
+
+    if !viper.IsSet("retry.attempts") {
+        t.Attempts = 3
+    }
+
+    if t.BaseDelay <= 0 {
+        t.BaseDelay = 500 * time.Millisecond
+    }
+
+    if t.MaxDelay <= 0 {
+        t.MaxDelay = 10 * time.Second
+    }
+
+    if t.AttemptTimeout <= 0 {
+        t.AttemptTimeout = 10 * time.Second
+    }
+
+    return t
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    // A request whose body cannot be read again is only sent once.
+    attempts := t.Attempts
+    if attempts < 1 || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+        attempts = 1
+    }
+
+    for attempt := 0; ; attempt++ {
+        resp, err := t.try(req)
+
+        if attempt + 1 >= attempts || req.Context().Err() != nil {
+            return resp, err
+        }
+
+        retry, delay := t.shouldRetry(resp, err, attempt)
+
+        if !retry {
+            return resp, err
+        }
+
+        if resp != nil {
+            io.Copy(io.Discard, resp.Body)
+            resp.Body.Close()
+        }
+
+        select {
+        case <-time.After(delay):
+        case <-req.Context().Done():
+            return nil, req.Context().Err()
+        }
+
+        if req.GetBody != nil {
+            if req.Body, err = req.GetBody(); err != nil {
+                return nil, err
+            }
+        }
+    }
+}
+
+// try sends a single attempt, bounded by the attempt timeout until its response body is closed.
+func (t *retryTransport) try(req *http.Request) (*http.Response, error) {
+    ctx, cancel := context.WithTimeout(req.Context(), t.AttemptTimeout)
+    resp, err := t.Base.RoundTrip(req.WithContext(ctx))
+
+    if err != nil {
+        cancel()
+        return nil, err
+    }
+
+    resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+    return resp, nil
+}
+
+// shouldRetry reports whether an attempt failed transiently, and how long to wait before the next one.
+func (t *retryTransport) shouldRetry(resp *http.Response, err error, attempt int) (bool, time.Duration) {
+    backoff := t.backoff(attempt)
+
+    if err != nil {
+        var netErr net.Error
+        transient := errors.Is(err, syscall.ECONNRESET) ||
+            errors.Is(err, io.EOF) ||
+            errors.Is(err, io.ErrUnexpectedEOF) ||
+            errors.Is(err, context.DeadlineExceeded) ||
+            (errors.As(err, &netErr) && netErr.Timeout())
+
+        return transient, backoff
+    }
+
+    switch {
+    case resp.StatusCode >= 500:
+    case resp.StatusCode == http.StatusTooManyRequests:
+    case resp.StatusCode == http.StatusForbidden && isSecondaryRateLimit(resp):
+    default:
+        return false, 0
+    }
+
+    if after, ok := retryAfter(resp); ok {
+        // Give up rather than hold the caller for longer than any backoff would.
+        return after <= t.MaxDelay, after
+    }
+
+    return true, backoff
+}
+
+// backoff doubles the delay with every attempt, up to the maximum, picking a random delay in its upper half.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+    d := t.MaxDelay
+    if attempt < 30 && t.BaseDelay << attempt < t.MaxDelay {
+        d = t.BaseDelay << attempt
+    }
+
+    return d / 2 + time.Duration(rand.Int63n(int64(d / 2) + 1))
+}
+
+// isSecondaryRateLimit tells GitHub's secondary rate limits apart from other 403 responses, keeping the body readable.
+func isSecondaryRateLimit(resp *http.Response) bool {
+    if resp.Header.Get("Retry-After") != "" {
+        return true
+    }
+
+    if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+        return false
+    }
+
+    b, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    resp.Body = io.NopCloser(bytes.NewReader(b))
+
+    return strings.Contains(strings.ToLower(string(b)), "secondary rate limit")
+}
+
+// retryAfter reads the Retry-After header, given in seconds or as a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+    v := resp.Header.Get("Retry-After")
+
+    if v == "" {
+        return 0, false
+    }
+
+    if seconds, err := strconv.Atoi(v); err == nil {
+        return time.Duration(seconds) * time.Second, true
+    }
+
+    if date, err := http.ParseTime(v); err == nil {
+        return time.Until(date), true
+    }
+
+    return 0, false
+}
+
+// cancelBody releases the context of an attempt once its response has been read.
+type cancelBody struct {
+    io.ReadCloser
+    cancel          context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+    defer b.cancel()
+    return b.ReadCloser.Close()
+}
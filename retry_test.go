@@ -0,0 +1,142 @@
+package main
+
+import (
+    "time"
+    "testing"
+    "net/http"
+    "sync/atomic"
+    "net/http/httptest"
+)
+
+// -- TESTS --
+
+func TestRetryTransport(t *testing.T) {
+    var retryTransportTestCases = []retryTestCase{
+        {
+            testName:   "A server error should be retried until it succeeds",
+            failures:   []func(w http.ResponseWriter){fail(http.StatusBadGateway), fail(http.StatusServiceUnavailable)},
+            status:     http.StatusOK,
+            attempts:   3,
+        },
+        {
+            testName:   "A server error should be returned once every attempt failed",
+            failures:   []func(w http.ResponseWriter){fail(http.StatusInternalServerError), fail(http.StatusInternalServerError), fail(http.StatusInternalServerError)},
+            status:     http.StatusInternalServerError,
+            attempts:   3,
+        },
+        {
+            testName:   "A client error should not be retried",
+            failures:   []func(w http.ResponseWriter){fail(http.StatusNotFound)},
+            status:     http.StatusNotFound,
+            attempts:   1,
+        },
+        {
+            testName:   "A secondary rate limit should be retried after its Retry-After",
+            failures:   []func(w http.ResponseWriter){secondaryRateLimit("0")},
+            status:     http.StatusOK,
+            attempts:   2,
+        },
+        {
+            testName:   "A secondary rate limit longer than the maximum delay should not be waited for",
+            failures:   []func(w http.ResponseWriter){secondaryRateLimit("60")},
+            status:     http.StatusForbidden,
+            attempts:   1,
+        },
+        {
+            testName:   "A primary rate limit should not be retried",
+            failures:   []func(w http.ResponseWriter){primaryRateLimit},
+            status:     http.StatusForbidden,
+            attempts:   1,
+        },
+        {
+            testName:   "A reset connection should be retried",
+            failures:   []func(w http.ResponseWriter){resetConnection},
+            status:     http.StatusOK,
+            attempts:   2,
+        },
+        {
+            testName:   "An attempt timing out should be retried",
+            failures:   []func(w http.ResponseWriter){func(http.ResponseWriter) { time.Sleep(200 * time.Millisecond) }},
+            status:     http.StatusOK,
+            attempts:   2,
+        },
+    }
+
+    for _, c := range retryTransportTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            // A timed out attempt may still be served while the next one is.
+            var attempts int32
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                if n := int(atomic.AddInt32(&attempts, 1)); n <= len(c.failures) {
+                    c.failures[n - 1](w)
+                }
+            }))
+            defer server.Close()
+
+            rt := &retryTransport{
+                Base: http.DefaultTransport,
+                Attempts: 3,
+                BaseDelay: time.Millisecond,
+                MaxDelay: 10 * time.Millisecond,
+                AttemptTimeout: 100 * time.Millisecond,
+            }
+
+            assertRetried(t, rt, server.URL, c.status, func() int { return int(atomic.LoadInt32(&attempts)) }, c.attempts)
+        })
+    }
+}
+
+// --- ASSERTS ---
+
+func assertRetried(t *testing.T, rt http.RoundTripper, url string, status int, attempts func() int, expected int) {
+    resp, err := (&http.Client{Transport: rt}).Get(url)
+
+    if err != nil {
+        t.Errorf("Request expected to succeed, but failed (%v)", err)
+        return
+    }
+    resp.Body.Close()
+
+    if resp.StatusCode != status {
+        t.Errorf("Status code (%d) expected to be %d", resp.StatusCode, status)
+    }
+
+    if attempts() != expected {
+        t.Errorf("Attempts (%d) expected to be (%d)", attempts(), expected)
+    }
+}
+
+// --- HELPERS ---
+
+func fail(status int) func(w http.ResponseWriter) {
+    return func(w http.ResponseWriter) {
+        w.WriteHeader(status)
+    }
+}
+
+func secondaryRateLimit(after string) func(w http.ResponseWriter) {
+    return func(w http.ResponseWriter) {
+        w.Header().Set("Retry-After", after)
+        w.WriteHeader(http.StatusForbidden)
+        w.Write([]byte(`{"message": "You have exceeded a secondary rate limit."}`))
+    }
+}
+
+func primaryRateLimit(w http.ResponseWriter) {
+    w.Header().Set("X-RateLimit-Remaining", "0")
+    w.WriteHeader(http.StatusForbidden)
+}
+
+func resetConnection(w http.ResponseWriter) {
+    conn, _, _ := w.(http.Hijacker).Hijack()
+    conn.Close()
+}
+
+// --- STRUCTS ---
+
+type retryTestCase struct {
+    testName    string
+    failures    []func(w http.ResponseWriter)
+    status      int
+    attempts    int
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+    "os"
+    "log"
+    "time"
+    "encoding/gob"
+    "path/filepath"
+
+    "github.com/spf13/viper"
+)
+
+// A snapshotEntry is a cache entry as it is written to a snapshot, still encoded, so its CachedAt is kept as is.
+type snapshotEntry struct {
+    Key             string
+    Entry           []byte
+}
+
+// snapshotPath returns where the in-memory cache is snapshotted, or "" when it is not.
+// The disk cache already outlives the process, so it is never snapshotted.
+func snapshotPath() string {
+    if _, ok := cache.(*DiskCache); ok {
+        return ""
+    }
+
+    return viper.GetString("cache.snapshot.path")
+}
+
+// saveSnapshot writes every entry of a cache to a file, replacing it atomically.
+func saveSnapshot(c Cache, p string) error {
+    var entries []snapshotEntry
+    rangeCache(c, func(key string, entry []byte) {
+        entries = append(entries, snapshotEntry{Key: key, Entry: entry})
+    })
+
+    f, err := os.CreateTemp(filepath.Dir(p), ".snapshot-")
+
+    if err != nil {
+        return err
+    }
+
+    err = gob.NewEncoder(f).Encode(entries)
+
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+
+    if err != nil {
+        os.Remove(f.Name())
+        return err
+    }
+
+    return os.Rename(f.Name(), p)
+}
+
+// restoreSnapshot loads the entries of a snapshot into a cache, skipping those past the retention period.
+// A missing snapshot restores nothing.
+func restoreSnapshot(c Cache, p string) (int, error) {
+    f, err := os.Open(p)
+
+    if os.IsNotExist(err) {
+        return 0, nil
+    }
+
+    if err != nil {
+        return 0, err
+    }
+    defer f.Close()
+
+    var entries []snapshotEntry
+    if err := gob.NewDecoder(f).Decode(&entries); err != nil {
+        return 0, err
+    }
+
+    restored := 0
+    for _, e := range entries {
+        if cachedAt, err := entryCachedAt(e.Entry); err != nil || time.Since(cachedAt) > cacheRetention() {
+            continue
+        }
+
+        if c.Set(e.Key, e.Entry) == nil {
+            restored++
+        }
+    }
+
+    return restored, nil
+}
+
+// snapshotEvery saves a snapshot periodically, so a crash loses at most an interval of entries.
+func snapshotEvery(p string, interval time.Duration) {
+    if interval <= 0 {
+        return
+    }
+
+    for range time.Tick(interval) {
+        if err := saveSnapshot(cache, p); err != nil {
+            log.Printf("Saving the cache snapshot failed: %v", err)
+        }
+    }
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+    "time"
+    "testing"
+    "path/filepath"
+
+    "github.com/allegro/bigcache/v3"
+)
+
+// -- TESTS --
+
+func TestCacheSnapshot(t *testing.T) {
+    big, _ := bigcache.New(ctx, bigcache.DefaultConfig(time.Hour))
+
+    var cacheSnapshotTestCases = []snapshotTestCase{
+        {
+            testName:   "Entries of bigcache should be restored with their CachedAt",
+            cache:      big,
+            cachedAt:   time.Now().Add(-time.Hour).Round(0),
+            expected:   1,
+        },
+        {
+            testName:   "Entries of the LRU cache should be restored with their CachedAt",
+            cache:      NewLRUCache(0, 0, time.Hour),
+            cachedAt:   time.Now().Add(-time.Hour).Round(0),
+            expected:   1,
+        },
+        {
+            testName:   "Entries past the retention period should not be restored",
+            cache:      NewLRUCache(0, 0, time.Hour),
+            cachedAt:   time.Now().Add(-2 * cacheRetention()),
+            expected:   0,
+        },
+    }
+
+    for _, c := range cacheSnapshotTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            assertSnapshotRestored(t, c.cache, c.cachedAt, c.expected)
+        })
+    }
+}
+
+func TestMissingCacheSnapshot(t *testing.T) {
+    n, err := restoreSnapshot(NewLRUCache(0, 0, 0), filepath.Join(t.TempDir(), "snapshot"))
+
+    if n != 0 || err != nil {
+        t.Errorf("Missing snapshot expected to restore nothing, not (%d, %v)", n, err)
+    }
+}
+
+// --- ASSERTS ---
+
+func assertSnapshotRestored(t *testing.T, c Cache, cachedAt time.Time, expected int) {
+    p := filepath.Join(t.TempDir(), "snapshot")
+
+    entry, _ := encodeEntry(LanguageResponse{
+        Code: &Code{Contents: "package main\n"},
+        Language: &Language{Name: "Go", Extension: ".go"},
+        CachedAt: cachedAt,
+    })
+    c.Set(languageKey("go"), entry)
+
+    if err := saveSnapshot(c, p); err != nil {
+        t.Errorf("Snapshot expected to be saved, but failed (%v)", err)
+        return
+    }
+
+    restored := NewLRUCache(0, 0, 0)
+    n, err := restoreSnapshot(restored, p)
+
+    if err != nil || n != expected {
+        t.Errorf("Entries restored (%d, %v) expected to be (%d)", n, err, expected)
+        return
+    }
+
+    if expected == 0 {
+        return
+    }
+
+    defer func(c Cache) { cache = c }(cache)
+    cache = restored
+
+    var res LanguageResponse
+    if err := cacheGet(languageKey("go"), &res); err != nil || !res.CachedAt.Equal(cachedAt) {
+        t.Errorf("Restored entry (%v, %v) expected to be cached at (%v)", res.CachedAt, err, cachedAt)
+    }
+}
+
+// --- STRUCTS ---
+
+type snapshotTestCase struct {
+    testName    string
+    cache       Cache
+    cachedAt    time.Time
+    expected    int
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+    "fmt"
+    "errors"
+    "regexp"
+    "time"
+    "context"
+    "strings"
+    "net/url"
+    "net/http"
+
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+// A Source is somewhere the catalog of languages and their "Hello World" programs can be read from.
+type Source interface {
+    // Languages lists every language in the catalog.
+    Languages(ctx context.Context) ([]*Language, error)
+
+    // Language resolves a requested language name to its entry in the catalog.
+    Language(ctx context.Context, l string) (*Language, error)
+
+    // Code fetches the "Hello World" program of a language.
+    Code(ctx context.Context, language *Language) (*Code, error)
+}
+
+// Validators are the upstream validators of a response, used to revalidate it once it expires.
+type Validators struct {
+    ETag            string
+    LastModified    string
+}
+
+// A ConditionalSource can skip fetching content that has not changed since it was cached.
+// Both methods return errNotModified when the content matches the validators.
+type ConditionalSource interface {
+    LanguagesIfModified(ctx context.Context, v Validators) ([]*Language, Validators, error)
+    CodeIfModified(ctx context.Context, language *Language, v Validators) (*Code, Validators, error)
+}
+
+var errNotModified = errors.New("not modified")
+
+// A CompleteSource builds its catalog from every file of the repository, rather than from the links of its README,
+// which may miss some. A language missing from a complete catalog does not exist.
+type CompleteSource interface {
+    CompleteCatalog() bool
+}
+
+// newSource builds the source used to serve a request, given its Authorization header.
+var newSource = newGitHubSource
+
+// sourceFactory returns the constructor of the configured type of source.
+func sourceFactory(t string) (func(string) Source, error) {
+    switch t {
+    case "", "github":
+        return newGitHubSource, nil
+    case "tree":
+        return newTreeSource, nil
+    case "filesystem":
+        return newFilesystemSource, nil
+    case "archive":
+        // The archive is indexed once and shared by every request.
+        s, err := newArchiveSource()
+
+        if err != nil {
+            return nil, err
+        }
+
+        return func(string) Source { return s }, nil
+    }
+
+    return nil, fmt.Errorf("unknown source type %q", t)
+}
+
+// fetchLanguages lists the catalog, conditionally if the source supports it.
+func fetchLanguages(ctx context.Context, src Source, v Validators) ([]*Language, Validators, error) {
+    if cs, ok := src.(ConditionalSource); ok {
+        return cs.LanguagesIfModified(ctx, v)
+    }
+
+    languages, err := src.Languages(ctx)
+    return languages, Validators{}, err
+}
+
+// hasCompleteCatalog reports whether a language missing from the catalog of a source does not exist.
+func hasCompleteCatalog(src Source) bool {
+    cs, ok := src.(CompleteSource)
+    return ok && cs.CompleteCatalog()
+}
+
+// fetchCode fetches the code of a language, conditionally if the source supports it.
+func fetchCode(ctx context.Context, src Source, language *Language, v Validators) (*Code, Validators, error) {
+    if cs, ok := src.(ConditionalSource); ok {
+        return cs.CodeIfModified(ctx, language, v)
+    }
+
+    code, err := src.Code(ctx, language)
+    return code, Validators{}, err
+}
+
+// GitHubSource reads the catalog from a GitHub repository through the GitHub API.
+type GitHubSource struct {
+    Client          *github.Client
+    User            string
+    Name            string
+}
+
+func newGitHubSource(auth string) Source {
+    return &GitHubSource{
+        Client: authorize(auth),
+        User: viper.GetString("repository.user"),
+        Name: viper.GetString("repository.name"),
+    }
+}
+
+func (s *GitHubSource) Languages(ctx context.Context) ([]*Language, error) {
+    languages, _, err := s.LanguagesIfModified(ctx, Validators{})
+    return languages, err
+}
+
+func (s *GitHubSource) Language(ctx context.Context, l string) (*Language, error) {
+    _, dir, _, err := s.Client.Repositories.GetContents(ctx, s.User, s.Name, bucket(l), nil)
+
+    if err != nil {
+        return nil, err
+    }
+
+    return findLanguage(dir, l)
+}
+
+func (s *GitHubSource) Code(ctx context.Context, language *Language) (*Code, error) {
+    code, _, err := s.CodeIfModified(ctx, language, Validators{})
+    return code, err
+}
+
+func (s *GitHubSource) LanguagesIfModified(ctx context.Context, v Validators) ([]*Language, Validators, error) {
+    // Get the README object.
+    readme, v, err := s.getIfModified(ctx, fmt.Sprintf("repos/%v/%v/readme", s.User, s.Name), v)
+
+    if err != nil {
+        return nil, v, err
+    }
+
+    // Get the README contents.
+    c, err := readme.GetContent()
+
+    if err != nil {
+        return nil, v, err
+    }
+
+    return findLanguages(c), v, nil
+}
+
+func (s *GitHubSource) CodeIfModified(ctx context.Context, language *Language, v Validators) (*Code, Validators, error) {
+    // Escape the path the way go-github does, as "#" is a valid directory name.
+    p := (&url.URL{Path: languagePath(language)}).String()
+    file, v, err := s.getIfModified(ctx, fmt.Sprintf("repos/%v/%v/contents/%v", s.User, s.Name, p), v)
+
+    if err != nil {
+        return nil, v, err
+    }
+
+    c, err := file.GetContent()
+
+    if err != nil {
+        return nil, v, err
+    }
+
+    return &Code{
+        Contents: c,
+    }, v, nil
+}
+
+// getIfModified gets a file from the contents API, unless it still matches the validators.
+// A request answered with 304 Not Modified does not count against the rate limit.
+func (s *GitHubSource) getIfModified(ctx context.Context, u string, v Validators) (*github.RepositoryContent, Validators, error) {
+    req, err := s.Client.NewRequest(http.MethodGet, u, nil)
+
+    if err != nil {
+        return nil, v, err
+    }
+
+    if v.ETag != "" {
+        req.Header.Set("If-None-Match", v.ETag)
+    }
+
+    if v.LastModified != "" {
+        req.Header.Set("If-Modified-Since", v.LastModified)
+    }
+
+    file := new(github.RepositoryContent)
+    resp, err := s.Client.Do(ctx, req, file)
+
+    if err, ok := err.(*github.ErrorResponse); ok && err.Response.StatusCode == http.StatusNotModified {
+        return nil, v, errNotModified
+    }
+
+    if err != nil {
+        return nil, v, err
+    }
+
+    return file, Validators{
+        ETag: resp.Header.Get("ETag"),
+        LastModified: resp.Header.Get("Last-Modified"),
+    }, nil
+}
+
+// bucket returns the directory a language is filed under: its lowercase initial, or "#" for anything else.
+func bucket(l string) string {
+    initial := strings.ToLower(l[0:1])
+
+    if !regexp.MustCompile("^[a-z]$").MatchString(initial) {
+        initial = "#"
+    }
+
+    return initial
+}
+
+// languagePath returns the path of a language's file, relative to the root of the repository.
+func languagePath(language *Language) string {
+    return bucket(language.Name) + "/" + language.Name + language.Extension
+}
+
+// sourceRefresh is how often the sources indexing the whole repository, archive and tree, read it again.
+// They never do when it is zero.
+func sourceRefresh() time.Duration {
+    if viper.IsSet("source.refresh") {
+        return viper.GetDuration("source.refresh")
+    }
+
+    return time.Hour
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+    "fmt"
+    "path"
+    "sort"
+    "time"
+    "net/url"
+    "strings"
+    "testing"
+    "net/http"
+    "crypto/sha1"
+    "sync/atomic"
+    "encoding/json"
+    "encoding/base64"
+    "net/http/httptest"
+
+    "github.com/google/go-github/v49/github"
+)
+
+// testRepository is the content of a small hello-world repository used by the offline tests.
+var testRepository = map[string]string{
+    "README.md":        "* [Go](g/Go.go)\n* [Node.js](n/Node.js.js)\n* [μλ](%23/μλ)\n",
+    "g/Go.go":          "package main\n",
+    "n/Node.js.js":     "console.log('Hello World')\n",
+    "#/μλ":             "Hello World\n",
+}
+
+// testNotModified counts the requests answered with 304 Not Modified by the test server.
+var testNotModified int32
+
+// testTreeFetches counts the git trees listed by the test server.
+var testTreeFetches int32
+
+// -- TESTS --
+
+func TestGitHubSource(t *testing.T) {
+    var gitHubSourceTestCases = []sourceTestCase{
+        {
+            testName:   "A language properly named should be resolved with its code",
+            language:   "go",
+            expected:   "package main\n",
+        },
+        {
+            testName:   "A language with a dot character should be resolved with its code",
+            language:   "node.js",
+            expected:   "console.log('Hello World')\n",
+        },
+        {
+            testName:   "A language with special characters should be resolved from the '#' directory",
+            language:   "μλ",
+            expected:   "Hello World\n",
+        },
+        {
+            testName:   "A language improperly named should not be resolved",
+            language:   "notalang",
+            expected:   "",
+        },
+    }
+
+    server := newTestGitHub(testRepository)
+    defer server.Close()
+
+    src := &GitHubSource{
+        Client: newTestGitHubClient(server),
+        User: "user",
+        Name: "repo",
+    }
+
+    t.Run("The catalog should list every language in the README", func(t *testing.T) {
+        assertSourceLanguages(t, src, []string{"Go", "Node.js", "μλ"})
+    })
+
+    for _, c := range gitHubSourceTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            assertSourceCode(t, src, c.language, c.expected)
+        })
+    }
+}
+
+func TestGitHubSourceIfModified(t *testing.T) {
+    var gitHubSourceIfModifiedTestCases = []ifModifiedTestCase{
+        {
+            testName:   "Code fetched without validators should be returned",
+            validators: Validators{},
+            expected:   nil,
+        },
+        {
+            testName:   "Code fetched with its current ETag should not be modified",
+            validators: Validators{ETag: `"` + testSHA(testRepository["g/Go.go"]) + `"`},
+            expected:   errNotModified,
+        },
+        {
+            testName:   "Code fetched with an outdated ETag should be returned",
+            validators: Validators{ETag: `"outdated"`},
+            expected:   nil,
+        },
+    }
+
+    server := newTestGitHub(testRepository)
+    defer server.Close()
+
+    src := &GitHubSource{
+        Client: newTestGitHubClient(server),
+        User: "user",
+        Name: "repo",
+    }
+
+    for _, c := range gitHubSourceIfModifiedTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            assertCodeIfModified(t, src, c.validators, c.expected)
+        })
+    }
+}
+
+func TestGetLanguageRevalidation(t *testing.T) {
+    server := newTestGitHub(testRepository)
+    defer server.Close()
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(string) Source {
+        return &GitHubSource{
+            Client: newTestGitHubClient(server),
+            User: "user",
+            Name: "repo",
+        }
+    }
+
+    cache.Reset()
+    assertSourceRoute(t, getLanguage, "/api/language/go", http.StatusOK, "package main\n")
+
+    // Expire the entry, keeping its validators.
+    var res LanguageResponse
+    cacheGet(languageKey("go"), &res)
+    res.CachedAt = time.Now().Add(-2 * languageTTL())
+    cacheSet(languageKey("go"), res)
+
+    notModified := atomic.LoadInt32(&testNotModified)
+    assertSourceRoute(t, getLanguage, "/api/language/go", http.StatusOK, "package main\n")
+
+    if atomic.LoadInt32(&testNotModified) != notModified + 1 {
+        t.Errorf("Expired entry expected to be revalidated with its ETag")
+    }
+
+    cacheGet(languageKey("go"), &res)
+    if !isFresh(res.CachedAt, languageTTL()) {
+        t.Errorf("Revalidated entry (%v) expected to be fresh", res.CachedAt)
+    }
+}
+
+// --- ASSERTS ---
+
+func assertCodeIfModified(t *testing.T, src ConditionalSource, v Validators, expected error) {
+    code, res, err := src.CodeIfModified(ctx, &Language{Name: "Go", Extension: ".go"}, v)
+
+    if err != expected {
+        t.Errorf("Error (%v) expected to be (%v)", err, expected)
+        return
+    }
+
+    if expected == nil && (code == nil || res.ETag == "") {
+        t.Errorf("Code expected to be returned with its ETag")
+    }
+}
+
+func assertSourceLanguages(t *testing.T, src Source, expected []string) {
+    languages, err := src.Languages(ctx)
+
+    if err != nil {
+        t.Errorf("Languages expected to be listed, but failed (%v)", err)
+        return
+    }
+
+    var names []string
+    for _, l := range languages {
+        names = append(names, l.Name)
+    }
+    sort.Strings(names)
+
+    if strings.Join(names, ",") != strings.Join(expected, ",") {
+        t.Errorf("Languages (%v) expected to be (%v)", names, expected)
+    }
+}
+
+func assertSourceCode(t *testing.T, src Source, l string, expected string) {
+    language, err := src.Language(ctx, l)
+
+    if expected == "" {
+        if err == nil {
+            t.Errorf("Language (%v) was resolved (%v), but was not expected", l, language.Name)
+        }
+        return
+    }
+
+    if err != nil {
+        t.Errorf("Language (%v) was not resolved (%v), but was expected", l, err)
+        return
+    }
+
+    code, err := src.Code(ctx, language)
+
+    if err != nil {
+        t.Errorf("Code of language (%v) was not fetched (%v), but was expected", l, err)
+        return
+    }
+
+    if code.Contents != expected {
+        t.Errorf("Code (%q) expected to be (%q)", code.Contents, expected)
+    }
+}
+
+// --- HELPERS ---
+
+// newTestGitHub serves the contents and git data APIs of a single repository, "user/repo", from a map of paths to file contents.
+func newTestGitHub(files map[string]string) *httptest.Server {
+    prefix := "/repos/user/repo/"
+
+    return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+
+        p := strings.TrimPrefix(r.URL.Path, prefix)
+
+        if p == "readme" {
+            p = "contents/README.md"
+        }
+
+        if strings.HasPrefix(p, "git/trees/") {
+            atomic.AddInt32(&testTreeFetches, 1)
+            json.NewEncoder(w).Encode(testTree(files))
+            return
+        }
+
+        if strings.HasPrefix(p, "git/blobs/") {
+            for _, c := range files {
+                if testSHA(c) == strings.TrimPrefix(p, "git/blobs/") {
+                    w.Write([]byte(c))
+                    return
+                }
+            }
+        }
+
+        p = strings.TrimPrefix(p, "contents/")
+
+        if c, ok := files[p]; ok {
+            etag := `"` + testSHA(c) + `"`
+            w.Header().Set("ETag", etag)
+
+            if r.Header.Get("If-None-Match") == etag {
+                atomic.AddInt32(&testNotModified, 1)
+                w.WriteHeader(http.StatusNotModified)
+                return
+            }
+
+            json.NewEncoder(w).Encode(testContent(p, c))
+            return
+        }
+
+        var dir []*github.RepositoryContent
+        for name, c := range files {
+            if path.Dir(name) == p {
+                dir = append(dir, testContent(name, c))
+            }
+        }
+
+        if len(dir) == 0 {
+            w.WriteHeader(http.StatusNotFound)
+            json.NewEncoder(w).Encode(struct { Message string `json:"message"` } {
+                Message: "Not Found",
+            })
+            return
+        }
+
+        json.NewEncoder(w).Encode(dir)
+    }))
+}
+
+// newTestGitHubClient returns a GitHub API client talking to a test server.
+func newTestGitHubClient(server *httptest.Server) *github.Client {
+    client := github.NewClient(nil)
+    client.BaseURL, _ = url.Parse(server.URL + "/")
+
+    return client
+}
+
+func testContent(p string, c string) *github.RepositoryContent {
+    return &github.RepositoryContent{
+        Type: github.String("file"),
+        Name: github.String(path.Base(p)),
+        Path: github.String(p),
+        Encoding: github.String("base64"),
+        Content: github.String(base64.StdEncoding.EncodeToString([]byte(c))),
+    }
+}
+
+func testTree(files map[string]string) *github.Tree {
+    var paths []string
+    for p := range files {
+        paths = append(paths, p)
+    }
+    sort.Strings(paths)
+
+    tree := &github.Tree{}
+    dirs := make(map[string]bool)
+
+    for _, p := range paths {
+        if d := path.Dir(p); d != "." && !dirs[d] {
+            dirs[d] = true
+            tree.Entries = append(tree.Entries, &github.TreeEntry{
+                Path: github.String(d),
+                Type: github.String("tree"),
+            })
+        }
+
+        tree.Entries = append(tree.Entries, &github.TreeEntry{
+            Path: github.String(p),
+            Type: github.String("blob"),
+            SHA: github.String(testSHA(files[p])),
+            Size: github.Int(len(files[p])),
+        })
+    }
+
+    return tree
+}
+
+func testSHA(c string) string {
+    return fmt.Sprintf("%x", sha1.Sum([]byte(c)))
+}
+
+// --- STRUCTS ---
+
+type ifModifiedTestCase struct {
+    testName    string
+    validators  Validators
+    expected    error
+}
+
+type sourceTestCase struct {
+    testName    string
+    language    string
+    expected    string
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+    "sync"
+    "time"
+    "expvar"
+    "net/http"
+    "encoding/json"
+
+    "github.com/allegro/bigcache/v3"
+)
+
+// RouteStats counts the cache lookups of a route.
+type RouteStats struct {
+    Hits            int64       `json:"hits"`
+    // Stale counts the expired entries served while they are revalidated.
+    Stale           int64       `json:"stale"`
+    Misses          int64       `json:"misses"`
+    DecodeErrors    int64       `json:"decode_errors"`
+    HitRatio        float64     `json:"hit_ratio"`
+}
+
+type CacheStatsResponse struct {
+    Entries         int         `json:"entries"`
+    // Backend holds the statistics of bigcache, when it is the cache in use.
+    Backend         *bigcache.Stats `json:"backend,omitempty"`
+    Routes          map[string]RouteStats `json:"routes"`
+    RequestedAt     time.Time   `json:"requested_at"`
+}
+
+// CacheStats counts the cache lookups of every route.
+type CacheStats struct {
+    mu              sync.Mutex
+    routes          map[string]*RouteStats
+}
+
+// A cacheOutcome is how a route answered a request from its cache.
+type cacheOutcome int
+
+const (
+    cacheHit cacheOutcome = iota
+    cacheStale
+    cacheMiss
+    cacheDecodeError
+)
+
+// cacheStats counts the lookups made by the routes, leaving out those made internally.
+var cacheStats = &CacheStats{}
+
+// publishCacheStats publishes the cache statistics as the "cache" expvar.
+func publishCacheStats() {
+    expvar.Publish("cache", expvar.Func(func() interface{} {
+        return newCacheStatsResponse()
+    }))
+}
+
+func getCacheStats(w http.ResponseWriter, r *http.Request) {
+    json.NewEncoder(w).Encode(newCacheStatsResponse())
+}
+
+func newCacheStatsResponse() CacheStatsResponse {
+    res := CacheStatsResponse{
+        Entries: cache.Len(),
+        Routes: cacheStats.All(),
+        RequestedAt: time.Now(),
+    }
+
+    if c, ok := cache.(*bigcache.BigCache); ok {
+        stats := c.Stats()
+        res.Backend = &stats
+    }
+
+    return res
+}
+
+// All returns the counters of every route, with their hit ratio.
+func (s *CacheStats) All() map[string]RouteStats {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    all := make(map[string]RouteStats)
+    for route, stats := range s.routes {
+        copied := *stats
+
+        if lookups := copied.Hits + copied.Stale + copied.Misses; lookups > 0 {
+            copied.HitRatio = float64(copied.Hits) / float64(lookups)
+        }
+
+        all[route] = copied
+    }
+
+    return all
+}
+
+// record counts a lookup of a route. An entry which fails to decode is a miss too.
+func (s *CacheStats) record(route string, outcome cacheOutcome) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.routes == nil {
+        s.routes = make(map[string]*RouteStats)
+    }
+
+    if s.routes[route] == nil {
+        s.routes[route] = &RouteStats{}
+    }
+    stats := s.routes[route]
+
+    switch outcome {
+    case cacheHit:
+        stats.Hits++
+    case cacheStale:
+        stats.Stale++
+    case cacheDecodeError:
+        stats.DecodeErrors++
+        stats.Misses++
+    default:
+        stats.Misses++
+    }
+}
+
+// Reset forgets every counter.
+func (s *CacheStats) Reset() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.routes = nil
+}
+
+// missOutcome tells a missing entry from one which failed to decode, given the error of cacheGet.
+func missOutcome(err error) cacheOutcome {
+    if err != nil && err != ErrEntryNotFound {
+        return cacheDecodeError
+    }
+
+    return cacheMiss
+}
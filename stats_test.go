@@ -0,0 +1,91 @@
+package main
+
+import (
+    "time"
+    "testing"
+    "encoding/json"
+    "net/http/httptest"
+)
+
+// -- TESTS --
+
+func TestCacheStats(t *testing.T) {
+    cache.Reset()
+    cacheStats.Reset()
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(string) Source {
+        return &fakeSource{"fake": "new"}
+    }
+
+    // The catalog cached by the first request is looked up again to find missing languages, but not counted.
+    serveStats(getLanguages, "/api/languages")
+    serveStats(getLanguage, "/api/language/fake")
+    serveStats(getLanguage, "/api/language/fake")
+    serveStats(getLanguage, "/api/language/missing")
+
+    cacheSet(languageKey("gone"), LanguageResponse{CachedAt: time.Now(), NotFound: true})
+    serveStats(getLanguage, "/api/language/gone")
+
+    cacheSet(languageKey("fake"), LanguageResponse{
+        Code: &Code{Contents: "old"},
+        Language: &Language{Name: "fake"},
+        CachedAt: time.Now().Add(-languageTTL() - time.Minute),
+    })
+    serveStats(getLanguage, "/api/language/fake")
+    assertRevalidated(t, languageKey("fake"), "new")
+
+    cache.Set("languages", []byte("not gob"))
+    serveStats(getLanguages, "/api/languages")
+
+    var cacheStatsTestCases = []cacheStatsTestCase{
+        {
+            testName:   "Fresh, negative, expired and missing languages should be counted apart",
+            route:      "language",
+            expected:   RouteStats{Hits: 2, Stale: 1, Misses: 2, HitRatio: 0.4},
+        },
+        {
+            testName:   "An entry which does not decode should be counted as a decoding error",
+            route:      "languages",
+            expected:   RouteStats{Hits: 0, Misses: 2, DecodeErrors: 1},
+        },
+    }
+
+    for _, c := range cacheStatsTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            if stats := cacheStats.All()[c.route]; stats != c.expected {
+                t.Errorf("Stats (%+v) expected to be (%+v)", stats, c.expected)
+            }
+        })
+    }
+
+    t.Run("The stats of bigcache should be reported", func(t *testing.T) {
+        w := httptest.NewRecorder()
+        getCacheStats(w, httptest.NewRequest("GET", "http://localhost:8080/api/admin/cache/stats", nil))
+
+        raw := &CacheStatsResponse{}
+        if err := json.NewDecoder(w.Result().Body).Decode(raw); err != nil || raw.Backend == nil {
+            t.Errorf("Response (%+v) expected to report the stats of bigcache (%v)", raw, err)
+            return
+        }
+
+        if raw.Backend.Hits == 0 || raw.Routes["language"].Hits != 2 {
+            t.Errorf("Stats (%+v, %+v) expected to count the hits", raw.Backend, raw.Routes)
+        }
+    })
+}
+
+// --- HELPERS ---
+
+// serveStats requests a route, only for the lookups it counts.
+func serveStats(fn handler, path string) {
+    fn(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080" + path, nil))
+}
+
+// --- STRUCTS ---
+
+type cacheStatsTestCase struct {
+    testName    string
+    route       string
+    expected    RouteStats
+}
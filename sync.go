@@ -0,0 +1,211 @@
+package main
+
+import (
+    "log"
+    "sync"
+    "errors"
+    "time"
+    "context"
+    "net/http"
+    "math/rand"
+    "encoding/json"
+
+    "github.com/spf13/viper"
+)
+
+// Syncer keeps the cache filled with the catalog and the code of every language, refreshing it in the background.
+type Syncer struct {
+    Interval        time.Duration
+    Jitter          time.Duration
+    Concurrency     int
+
+    mu              sync.Mutex
+    status          SyncStatus
+}
+
+type SyncStatus struct {
+    Enabled         bool        `json:"enabled"`
+    Running         bool        `json:"running"`
+    LastStarted     time.Time   `json:"last_started"`
+    LastSuccess     time.Time   `json:"last_success"`
+    LastError       string      `json:"last_error"`
+    LastErrorAt     time.Time   `json:"last_error_at"`
+    Languages       int         `json:"languages"`
+    Synced          int         `json:"synced"`
+    Failed          int         `json:"failed"`
+}
+
+// syncer is the background synchronizer, if it is enabled.
+var syncer *Syncer
+
+// errSyncRunning is returned when a synchronization is started while another one is running.
+var errSyncRunning = errors.New("the cache is already being synchronized")
+
+func newSyncer() *Syncer {
+    s := &Syncer{
+        Interval: viper.GetDuration("sync.interval"),
+        Jitter: viper.GetDuration("sync.jitter"),
+        Concurrency: viper.GetInt("sync.concurrency"),
+    }
+
+    if s.Interval <= 0 {
+        s.Interval = time.Hour
+    }
+
+    if s.Concurrency <= 0 {
+        s.Concurrency = 4
+    }
+
+    return s
+}
+
+func getSync(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+
+    if syncer == nil {
+        json.NewEncoder(w).Encode(SyncStatus{})
+        return
+    }
+
+    json.NewEncoder(w).Encode(syncer.Status())
+}
+
+// Start synchronizes immediately, then again after every interval (plus a random jitter).
+func (s *Syncer) Start() {
+    go func() {
+        for {
+            if err := s.Sync(ctx); err != nil {
+                log.Printf("Synchronizing the catalog failed: %v", err)
+            }
+
+            d := s.Interval
+            if s.Jitter > 0 {
+                d += time.Duration(rand.Int63n(int64(s.Jitter)))
+            }
+
+            time.Sleep(d)
+        }
+    }()
+}
+
+// Sync fetches the catalog and the code of every language in it, storing them in the cache.
+func (s *Syncer) Sync(ctx context.Context) error {
+    if !s.begin() {
+        return errSyncRunning
+    }
+
+    return s.end(s.sync(ctx))
+}
+
+// begin marks a synchronization as running, unless one already is, in which case it reports false.
+func (s *Syncer) begin() bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.status.Running {
+        return false
+    }
+
+    s.status.Running = true
+    s.status.LastStarted = time.Now()
+
+    return true
+}
+
+// end records the outcome of the running synchronization.
+func (s *Syncer) end(synced int, failed int, err error) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.status.Running = false
+    s.status.Synced = synced
+    s.status.Failed = failed
+
+    if err != nil {
+        s.status.LastError = err.Error()
+        s.status.LastErrorAt = time.Now()
+        return err
+    }
+
+    s.status.LastSuccess = time.Now()
+    return nil
+}
+
+func (s *Syncer) sync(ctx context.Context) (synced int, failed int, err error) {
+    src := newSource("")
+
+    // Cached entries are revalidated, so what has not changed does not count against the rate limit.
+    var cached LanguagesResponse
+    cacheGet("languages", &cached)
+
+    languages, v, err := fetchLanguages(ctx, src, cached.Validators)
+
+    if err == errNotModified {
+        languages, v, err = cached.Languages, cached.Validators, nil
+    } else if err != nil {
+        return
+    }
+
+    s.mu.Lock()
+    s.status.Languages = len(languages)
+    s.mu.Unlock()
+
+    cacheSet("languages", LanguagesResponse{
+        Languages: languages,
+        CachedAt: time.Now(),
+        Validators: v,
+    })
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    sem := make(chan struct{}, s.Concurrency)
+
+    for _, language := range languages {
+        wg.Add(1)
+        sem <- struct{}{}
+
+        go func(language *Language) {
+            defer func() { <-sem; wg.Done() }()
+
+            var cached LanguageResponse
+            cacheGet(languageKey(language.Name), &cached)
+
+            code, v, e := fetchCode(ctx, src, language, cached.Validators)
+
+            if e == errNotModified {
+                code, v, e = cached.Code, cached.Validators, nil
+            }
+
+            mu.Lock()
+            defer mu.Unlock()
+
+            if e != nil {
+                failed++
+                err = e
+                return
+            }
+
+            synced++
+            cacheSet(languageKey(language.Name), LanguageResponse{
+                Code: code,
+                Language: language,
+                CachedAt: time.Now(),
+                Validators: v,
+            })
+        }(language)
+    }
+
+    wg.Wait()
+
+    return
+}
+
+func (s *Syncer) Status() SyncStatus {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    status := s.status
+    status.Enabled = true
+
+    return status
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+    "os"
+    "testing"
+    "sync/atomic"
+    "path/filepath"
+)
+
+// -- TESTS --
+
+func TestSync(t *testing.T) {
+    var syncTestCases = []syncTestCase{
+        {
+            testName:   "Every language of the catalog should be synchronized",
+            languages:  map[string]string{"Go": "package main\n", "Ruby": "puts 'Hello World'\n"},
+            concurrency: 1,
+        },
+        {
+            testName:   "Languages synchronized concurrently should all be synchronized",
+            languages:  map[string]string{"A": "a", "B": "b", "C": "c", "D": "d", "E": "e"},
+            concurrency: 3,
+        },
+    }
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+
+    for _, c := range syncTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+
+            src := fakeSource(c.languages)
+            newSource = func(string) Source { return &src }
+
+            s := &Syncer{Concurrency: c.concurrency}
+            if err := s.Sync(ctx); err != nil {
+                t.Errorf("Sync expected to succeed, but failed (%v)", err)
+                return
+            }
+
+            assertSync(t, s, c.languages)
+        })
+    }
+}
+
+func TestSyncMissingFile(t *testing.T) {
+    root := t.TempDir()
+    os.MkdirAll(filepath.Join(root, "g"), 0755)
+    os.WriteFile(filepath.Join(root, "README.md"), []byte("* [Go](g/Go.go)\n* [Ruby](r/Ruby.rb)\n"), 0644)
+    os.WriteFile(filepath.Join(root, "g", "Go.go"), []byte("package main\n"), 0644)
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(string) Source { return &FilesystemSource{Root: root} }
+
+    cache.Reset()
+
+    s := &Syncer{Concurrency: 1}
+    if err := s.Sync(ctx); err == nil {
+        t.Errorf("Sync of a catalog entry without a file expected to fail")
+    }
+
+    status := s.Status()
+    if status.Synced != 1 || status.Failed != 1 || status.LastError == "" {
+        t.Errorf("Status (%+v) expected to report the language without a file", status)
+    }
+}
+
+func TestSyncRevalidation(t *testing.T) {
+    server := newTestGitHub(testRepository)
+    defer server.Close()
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(string) Source {
+        return &GitHubSource{
+            Client: newTestGitHubClient(server),
+            User: "user",
+            Name: "repo",
+        }
+    }
+
+    cache.Reset()
+
+    s := &Syncer{Concurrency: 1}
+    s.Sync(ctx)
+
+    notModified := atomic.LoadInt32(&testNotModified)
+
+    if err := s.Sync(ctx); err != nil {
+        t.Errorf("Sync expected to succeed, but failed (%v)", err)
+        return
+    }
+
+    // The README and the file of every language.
+    if n := atomic.LoadInt32(&testNotModified) - notModified; n != 4 {
+        t.Errorf("Unchanged files revalidated (%d) expected to be (%d)", n, 4)
+    }
+
+    assertSync(t, s, map[string]string{"Go": "package main\n", "Node.js": "console.log('Hello World')\n", "μλ": "Hello World\n"})
+}
+
+// --- ASSERTS ---
+
+func assertSync(t *testing.T, s *Syncer, expected map[string]string) {
+    var languages LanguagesResponse
+    if err := cacheGet("languages", &languages); err != nil || len(languages.Languages) != len(expected) {
+        t.Errorf("Catalog expected to be cached with %d languages", len(expected))
+    }
+
+    for name, code := range expected {
+        var res LanguageResponse
+        if err := cacheGet(languageKey(name), &res); err != nil || res.Code.Contents != code {
+            t.Errorf("Language (%v) expected to be cached with its code (%q)", name, code)
+        }
+    }
+
+    status := s.Status()
+    if status.Languages != len(expected) || status.Synced != len(expected) || status.Failed != 0 || status.LastSuccess.IsZero() {
+        t.Errorf("Status (%+v) expected to report %d languages synchronized", status, len(expected))
+    }
+}
+
+// --- STRUCTS ---
+
+type syncTestCase struct {
+    testName    string
+    languages   map[string]string
+    concurrency int
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+    "net/http"
+
+    "golang.org/x/oauth2"
+)
+
+// upstreamTransport is the transport every request to GitHub with the service's credentials goes through, after
+// they are added.
+func upstreamTransport() http.RoundTripper {
+    return &rateLimitTransport{
+        Base: callerTransport(),
+    }
+}
+
+// callerTransport is the transport of the requests made with a caller's own token. Their rate limits are not
+// recorded, since callers may send any number of tokens, valid or not.
+func callerTransport() http.RoundTripper {
+    return &breakerTransport{
+        Base: newRetryTransport(http.DefaultTransport),
+    }
+}
+
+// tokenClient returns an HTTP client authenticating its requests to GitHub with tokens from a source.
+func tokenClient(ts oauth2.TokenSource) *http.Client {
+    return &http.Client{
+        Transport: &oauth2.Transport{
+            Source: ts,
+            Base: upstreamTransport(),
+        },
+    }
+}
+
+// callerClient returns an HTTP client authenticating its requests to GitHub with a caller's own token.
+func callerClient(ts oauth2.TokenSource) *http.Client {
+    return &http.Client{
+        Transport: &oauth2.Transport{
+            Source: ts,
+            Base: callerTransport(),
+        },
+    }
+}
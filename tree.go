@@ -0,0 +1,253 @@
+package main
+
+import (
+    "log"
+    "path"
+    "sync"
+    "time"
+    "regexp"
+    "context"
+    "strings"
+
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+// TreeSource reads the catalog from a recursive listing of the repository's git tree, rather than its README.
+type TreeSource struct {
+    Client          *github.Client
+    User            string
+    Name            string
+    Branch          string
+
+    // indexes holds the listed tree, under the cache scope of the caller. A source without one lists the tree once.
+    indexes         *treeIndexGroup
+    scope           string
+}
+
+// A treeIndex holds the blobs of the catalog, by path, as listed from the tree. It is shared by the sources of every
+// request in a cache scope, and listed again once it is older than the refresh interval.
+type treeIndex struct {
+    blobs           map[string]*github.TreeEntry
+    paths           []string
+    fetchedAt       time.Time
+}
+
+// treeIndexMax is how many cache scopes a tree index is kept for at most, each caller's token being one when the
+// repository is private.
+const treeIndexMax = 64
+
+// treeIndexGroup holds the tree indexes of the cache scopes whose tree was listed lately.
+type treeIndexGroup struct {
+    mu              sync.Mutex
+    indexes         map[string]*treeIndex
+
+    // listings coalesces the concurrent listings of the tree of a scope.
+    listings        flightGroup
+}
+
+var treeIndexes = &treeIndexGroup{}
+
+// get returns the tree index of a cache scope, unless it was never listed or must be listed again.
+func (t *treeIndexGroup) get(scope string) *treeIndex {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    if i := t.indexes[scope]; i != nil && i.fresh() {
+        return i
+    }
+
+    return nil
+}
+
+// set keeps the tree index of a cache scope, dropping those to be listed again, and the oldest one when full.
+func (t *treeIndexGroup) set(scope string, i *treeIndex) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    if t.indexes == nil {
+        t.indexes = make(map[string]*treeIndex)
+    }
+
+    oldest := ""
+    for s, kept := range t.indexes {
+        if !kept.fresh() {
+            delete(t.indexes, s)
+        } else if oldest == "" || kept.fetchedAt.Before(t.indexes[oldest].fetchedAt) {
+            oldest = s
+        }
+    }
+
+    if _, ok := t.indexes[scope]; !ok && len(t.indexes) >= treeIndexMax {
+        delete(t.indexes, oldest)
+    }
+
+    t.indexes[scope] = i
+}
+
+// reset forgets every tree index, so they are listed again.
+func (t *treeIndexGroup) reset() {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    t.indexes = nil
+}
+
+// fresh reports whether a tree index is recent enough to be served.
+func (i *treeIndex) fresh() bool {
+    return sourceRefresh() <= 0 || time.Since(i.fetchedAt) < sourceRefresh()
+}
+
+func newTreeSource(auth string) Source {
+    branch := viper.GetString("repository.branch")
+
+    if branch == "" {
+        branch = "HEAD"
+    }
+
+    return &TreeSource{
+        Client: authorize(auth),
+        User: viper.GetString("repository.user"),
+        Name: viper.GetString("repository.name"),
+        Branch: branch,
+        indexes: treeIndexes,
+        scope: cacheScope(auth),
+    }
+}
+
+// CompleteCatalog reports that the catalog lists every language file of the tree.
+func (s *TreeSource) CompleteCatalog() bool {
+    return true
+}
+
+func (s *TreeSource) Languages(ctx context.Context) ([]*Language, error) {
+    _, paths, err := s.fetch(ctx)
+
+    if err != nil {
+        return nil, err
+    }
+
+    var languages []*Language
+    for _, p := range paths {
+        languages = append(languages, languageFromPath(p))
+    }
+
+    return languages, nil
+}
+
+func (s *TreeSource) Language(ctx context.Context, l string) (*Language, error) {
+    _, paths, err := s.fetch(ctx)
+
+    if err != nil {
+        return nil, err
+    }
+
+    var dir []*github.RepositoryContent
+    for _, p := range paths {
+        if path.Dir(p) == bucket(l) {
+            dir = append(dir, &github.RepositoryContent{
+                Name: github.String(path.Base(p)),
+            })
+        }
+    }
+
+    return findLanguage(dir, l)
+}
+
+func (s *TreeSource) Code(ctx context.Context, language *Language) (*Code, error) {
+    blobs, _, err := s.fetch(ctx)
+
+    if err != nil {
+        return nil, err
+    }
+
+    blob, ok := blobs[languagePath(language)]
+
+    if !ok {
+        return nil, notFound()
+    }
+
+    b, _, err := s.Client.Git.GetBlobRaw(ctx, s.User, s.Name, blob.GetSHA())
+
+    if err != nil {
+        return nil, err
+    }
+
+    return &Code{
+        Contents: string(b),
+    }, nil
+}
+
+// fetch returns the index of the tree of the branch, listing the tree first if it has not been or is outdated.
+// The index is replaced rather than modified, so what is returned is safe to read without the lock.
+func (s *TreeSource) fetch(ctx context.Context) (map[string]*github.TreeEntry, []string, error) {
+    if s.indexes == nil {
+        s.indexes = &treeIndexGroup{}
+    }
+
+    if i := s.indexes.get(s.scope); i != nil {
+        return i.blobs, i.paths, nil
+    }
+
+    // Only a tree listed successfully is kept, so the tokens GitHub refuses are never indexed.
+    res, err := s.indexes.listings.Do(ctx, s.scope, func() (interface{}, error) {
+        i, err := s.list(ctx)
+
+        if err != nil {
+            return nil, err
+        }
+
+        s.indexes.set(s.scope, i)
+
+        return i, nil
+    })
+
+    if err != nil {
+        return nil, nil, err
+    }
+
+    i := res.(*treeIndex)
+
+    return i.blobs, i.paths, nil
+}
+
+// list lists the tree of the branch, indexing the files directly within a language directory.
+func (s *TreeSource) list(ctx context.Context) (*treeIndex, error) {
+    tree, _, err := s.Client.Git.GetTree(ctx, s.User, s.Name, s.Branch, true)
+
+    if err != nil {
+        return nil, err
+    }
+
+    if tree.GetTruncated() {
+        log.Printf("The tree of %s/%s@%s was truncated, some languages are missing", s.User, s.Name, s.Branch)
+    }
+
+    // Find files directly within a language directory: "g/Go.go" or "#/μλ".
+    re := regexp.MustCompile("^(?:[a-z]|#)/[^/]+$")
+
+    i := &treeIndex{
+        blobs: make(map[string]*github.TreeEntry),
+        fetchedAt: time.Now(),
+    }
+
+    for _, e := range tree.Entries {
+        if e.GetType() == "blob" && re.MatchString(e.GetPath()) {
+            i.blobs[e.GetPath()] = e
+            i.paths = append(i.paths, e.GetPath())
+        }
+    }
+
+    return i, nil
+}
+
+// languageFromPath describes the language of a file in the repository.
+func languageFromPath(p string) *Language {
+    name := path.Base(p)
+    ext := path.Ext(name)
+
+    return &Language{
+        Name: strings.TrimSuffix(name, ext),
+        Extension: ext,
+    }
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+    "fmt"
+    "time"
+    "testing"
+    "net/http"
+    "sync/atomic"
+    "net/http/httptest"
+)
+
+// -- TESTS --
+
+func TestTreeSource(t *testing.T) {
+    var treeSourceTestCases = []sourceTestCase{
+        {
+            testName:   "A language properly named should be resolved with its code",
+            language:   "go",
+            expected:   "package main\n",
+        },
+        {
+            testName:   "A language missing from the README should be resolved with its code",
+            language:   "ruby",
+            expected:   "puts 'Hello World'\n",
+        },
+        {
+            testName:   "A language with special characters should be resolved from the '#' directory",
+            language:   "μλ",
+            expected:   "Hello World\n",
+        },
+        {
+            testName:   "A file outside of a language directory should not be resolved",
+            language:   "readme",
+            expected:   "",
+        },
+    }
+
+    files := map[string]string{
+        "r/Ruby.rb":            "puts 'Hello World'\n",
+        "docs/CONTRIBUTING.md": "Add a language\n",
+    }
+    for p, c := range testRepository {
+        files[p] = c
+    }
+
+    server := newTestGitHub(files)
+    defer server.Close()
+
+    src := func() *TreeSource {
+        return &TreeSource{
+            Client: newTestGitHubClient(server),
+            User: "user",
+            Name: "repo",
+            Branch: "main",
+        }
+    }
+
+    t.Run("The catalog should list every file in a language directory", func(t *testing.T) {
+        assertSourceLanguages(t, src(), []string{"Go", "Node.js", "Ruby", "μλ"})
+    })
+
+    for _, c := range treeSourceTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            assertSourceCode(t, src(), c.language, c.expected)
+        })
+    }
+}
+
+func TestTreeSourceShared(t *testing.T) {
+    server := newTestGitHub(testRepository)
+    defer server.Close()
+
+    defer func(fn func(string) Source) { newSource = fn }(newSource)
+    newSource = func(auth string) Source {
+        return &TreeSource{
+            Client: newTestGitHubClient(server),
+            User: "user",
+            Name: "repo",
+            Branch: "main",
+            indexes: treeIndexes,
+            scope: cacheScope(auth),
+        }
+    }
+
+    cache.Reset()
+    treeIndexes.reset()
+    fetches := atomic.LoadInt32(&testTreeFetches)
+
+    assertSourceRoute(t, getLanguage, "/api/language/go", http.StatusOK, "package main\n")
+    assertSourceRoute(t, getLanguage, "/api/language/node.js", http.StatusOK, "console.log('Hello World')\n")
+
+    if n := atomic.LoadInt32(&testTreeFetches) - fetches; n != 1 {
+        t.Errorf("Trees listed (%d) expected to be (%d) across requests", n, 1)
+    }
+
+    treeIndexes.reset()
+    assertSourceRoute(t, getLanguage, "/api/language/μλ", http.StatusOK, "Hello World\n")
+
+    if n := atomic.LoadInt32(&testTreeFetches) - fetches; n != 2 {
+        t.Errorf("Trees listed (%d) expected to be (%d) once reset", n, 2)
+    }
+}
+
+func TestTreeIndexes(t *testing.T) {
+    t.Run("A tree GitHub refuses to list should not be indexed", func(t *testing.T) {
+        server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.WriteHeader(http.StatusUnauthorized)
+        }))
+        defer server.Close()
+
+        indexes := &treeIndexGroup{}
+        src := &TreeSource{
+            Client: newTestGitHubClient(server),
+            User: "user",
+            Name: "repo",
+            Branch: "main",
+            indexes: indexes,
+            scope: "token:bogus/",
+        }
+
+        if _, err := src.Languages(ctx); err == nil || len(indexes.indexes) != 0 {
+            t.Errorf("Refused tree expected not to be indexed, got (%d) indexes (%v)", len(indexes.indexes), err)
+        }
+    })
+
+    t.Run("The tree indexes should be bounded, dropping the oldest", func(t *testing.T) {
+        indexes := &treeIndexGroup{}
+        for n := 0; n < treeIndexMax + 10; n++ {
+            fetchedAt := time.Now().Add(time.Duration(n - treeIndexMax - 10) * time.Second)
+            indexes.set(fmt.Sprintf("token:%d/", n), &treeIndex{fetchedAt: fetchedAt})
+        }
+
+        if len(indexes.indexes) != treeIndexMax || indexes.get("token:0/") != nil {
+            t.Errorf("Tree indexes (%d) expected to be bounded to (%d), dropping the oldest", len(indexes.indexes), treeIndexMax)
+        }
+    })
+
+    t.Run("A tree index older than the refresh interval should be dropped", func(t *testing.T) {
+        indexes := &treeIndexGroup{}
+        indexes.set("token:old/", &treeIndex{fetchedAt: time.Now().Add(-sourceRefresh() - time.Minute)})
+        indexes.set("token:new/", &treeIndex{fetchedAt: time.Now()})
+
+        if len(indexes.indexes) != 1 || indexes.get("token:new/") == nil {
+            t.Errorf("Tree indexes (%d) expected to hold the fresh one only", len(indexes.indexes))
+        }
+    })
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+    "mime"
+    "sort"
+    "errors"
+    "regexp"
+    "strings"
+    "net/http"
+    "encoding/json"
+
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+// webhookMaxSize is the size of the largest payload GitHub delivers, 25 MB.
+const webhookMaxSize = 25 << 20
+
+type WebhookResponse struct {
+    Event           string      `json:"event"`
+    Invalidated     []string    `json:"invalidated"`
+}
+
+// postGitHubWebhook invalidates the cache entries of the files changed by a push to the repository.
+func postGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+
+    secret := viper.GetString("webhook.secret")
+
+    // Without a secret, GitHub's payload validation would accept any request.
+    if secret == "" {
+        writeError(w, r, &ErrorResponse{
+            StatusCode: http.StatusNotFound,
+            Message: "Webhooks are not configured",
+        })
+        return
+    }
+
+    contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    payload, err := github.ValidatePayloadFromBody(
+        contentType,
+        http.MaxBytesReader(w, r.Body, webhookMaxSize),
+        r.Header.Get(github.SHA256SignatureHeader),
+        []byte(secret),
+    )
+
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+        writeError(w, r, &ErrorResponse{
+            StatusCode: http.StatusRequestEntityTooLarge,
+            Message: err.Error(),
+        })
+        return
+    } else if err != nil {
+        writeError(w, r, &ErrorResponse{
+            StatusCode: http.StatusUnauthorized,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    event, err := github.ParseWebHook(github.WebHookType(r), payload)
+
+    if err != nil {
+        writeError(w, r, &ErrorResponse{
+            StatusCode: http.StatusBadRequest,
+            Message: err.Error(),
+        })
+        return
+    }
+
+    res := WebhookResponse{
+        Event: github.WebHookType(r),
+        Invalidated: []string{},
+    }
+
+    if e, ok := event.(*github.PushEvent); ok && isTrackedPush(e) {
+        // The tree of the branch changed too.
+        treeIndexes.reset()
+
+        for _, key := range pushedKeys(e) {
+            if invalidate(key) {
+                res.Invalidated = append(res.Invalidated, key)
+            }
+        }
+    }
+
+    json.NewEncoder(w).Encode(res)
+}
+
+// invalidate deletes a cache entry in the scope of every caller, reporting whether there was any.
+func invalidate(key string) bool {
+    var keys []string
+    rangeCache(cache, func(scoped string, _ []byte) {
+        if isScopedKey(scoped, key) {
+            keys = append(keys, scoped)
+        }
+    })
+
+    deleted := cache.Delete(key) == nil
+    for _, scoped := range keys {
+        deleted = cache.Delete(scoped) == nil || deleted
+    }
+
+    return deleted
+}
+
+// isTrackedPush reports whether a push is to the repository and branch languages are served from.
+func isTrackedPush(e *github.PushEvent) bool {
+    // A webhook may be shared by several repositories, all signing with the same secret.
+    repository := viper.GetString("repository.user") + "/" + viper.GetString("repository.name")
+
+    if !strings.EqualFold(e.GetRepo().GetFullName(), repository) {
+        return false
+    }
+
+    branch := viper.GetString("repository.branch")
+
+    if branch == "" || branch == "HEAD" {
+        branch = e.GetRepo().GetDefaultBranch()
+    }
+
+    return e.GetRef() == "refs/heads/" + branch
+}
+
+// pushedKeys returns the cache keys of the languages changed by a push, and of the catalog if it may have changed.
+func pushedKeys(e *github.PushEvent) []string {
+    re := regexp.MustCompile("^(?:[a-z]|#)/[^/]+$")
+    keys := make(map[string]bool)
+
+    for _, c := range e.Commits {
+        // Adding or removing a language changes the catalog, as does editing the README it is listed in.
+        changed := append(append([]string{}, c.Added...), c.Removed...)
+        for _, p := range changed {
+            if re.MatchString(p) {
+                keys["languages"] = true
+            }
+        }
+
+        for _, p := range append(changed, c.Modified...) {
+            if p == "README.md" {
+                keys["languages"] = true
+            } else if re.MatchString(p) {
+                keys[languageKey(languageFromPath(p).Name)] = true
+            }
+        }
+    }
+
+    var sorted []string
+    for key := range keys {
+        sorted = append(sorted, key)
+    }
+    sort.Strings(sorted)
+
+    return sorted
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+    "io"
+    "fmt"
+    "bytes"
+    "testing"
+    "net/http"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/json"
+    "net/http/httptest"
+
+    "github.com/spf13/viper"
+    "github.com/google/go-github/v49/github"
+)
+
+// -- TESTS --
+
+func TestPostGitHubWebhook(t *testing.T) {
+    var postGitHubWebhookTestCases = []webhookTestCase{
+        {
+            testName:   "A push modifying a language should invalidate only that language",
+            secret:     "secret",
+            repo:       "user/repo",
+            ref:        "refs/heads/main",
+            commit:     &github.HeadCommit{Modified: []string{"g/Go.go"}},
+            status:     http.StatusOK,
+            expected:   []string{"language-go"},
+        },
+        {
+            testName:   "A push modifying the README should invalidate the catalog",
+            secret:     "secret",
+            repo:       "user/repo",
+            ref:        "refs/heads/main",
+            commit:     &github.HeadCommit{Modified: []string{"README.md"}},
+            status:     http.StatusOK,
+            expected:   []string{"languages"},
+        },
+        {
+            testName:   "A push adding a language should invalidate the catalog and that language",
+            secret:     "secret",
+            repo:       "user/repo",
+            ref:        "refs/heads/main",
+            commit:     &github.HeadCommit{Added: []string{"r/Ruby.rb"}, Modified: []string{".github/workflow.yml"}},
+            status:     http.StatusOK,
+            expected:   []string{"language-ruby", "languages"},
+        },
+        {
+            testName:   "A push to another branch should not invalidate anything",
+            secret:     "secret",
+            repo:       "user/repo",
+            ref:        "refs/heads/feature",
+            commit:     &github.HeadCommit{Modified: []string{"g/Go.go", "README.md"}},
+            status:     http.StatusOK,
+            expected:   []string{},
+        },
+        {
+            testName:   "A push signed with the wrong secret should be refused",
+            secret:     "notthesecret",
+            repo:       "user/repo",
+            ref:        "refs/heads/main",
+            commit:     &github.HeadCommit{Modified: []string{"g/Go.go"}},
+            status:     http.StatusUnauthorized,
+            expected:   nil,
+        },
+        {
+            testName:   "A push to another repository should not invalidate anything",
+            secret:     "secret",
+            repo:       "user/fork",
+            ref:        "refs/heads/main",
+            commit:     &github.HeadCommit{Modified: []string{"g/Go.go", "README.md"}},
+            status:     http.StatusOK,
+            expected:   []string{},
+        },
+        {
+            testName:   "A payload larger than GitHub delivers should be refused",
+            secret:     "secret",
+            repo:       "user/repo",
+            ref:        "refs/heads/main",
+            size:       webhookMaxSize + 1,
+            commit:     &github.HeadCommit{Modified: []string{"g/Go.go"}},
+            status:     http.StatusRequestEntityTooLarge,
+            expected:   nil,
+        },
+    }
+
+    defer viper.Set("webhook.secret", viper.Get("webhook.secret"))
+    defer viper.Set("repository.user", viper.Get("repository.user"))
+    defer viper.Set("repository.name", viper.Get("repository.name"))
+    viper.Set("webhook.secret", "secret")
+    viper.Set("repository.user", "User")
+    viper.Set("repository.name", "repo")
+
+    for _, c := range postGitHubWebhookTestCases {
+        t.Run(c.testName, func(t *testing.T) {
+            cache.Reset()
+            for _, key := range []string{"languages", "language-go", "language-ruby", "language-c", "token:caller/language-go"} {
+                cache.Set(key, []byte{})
+            }
+
+            payload := testPushPayload(c.repo, c.ref, c.commit)
+
+            // Trailing whitespace pads the payload without making it invalid.
+            if c.size > len(payload) {
+                payload = append(payload, bytes.Repeat([]byte(" "), c.size - len(payload))...)
+            }
+
+            assertPostGitHubWebhook(t, payload, c.secret, c.status, c.expected)
+        })
+    }
+}
+
+// --- ASSERTS ---
+
+func assertPostGitHubWebhook(t *testing.T, payload []byte, secret string, status int, expected []string) {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write(payload)
+
+    req := httptest.NewRequest("POST", "http://localhost:8080/api/webhooks/github", bytes.NewReader(payload))
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set(github.EventTypeHeader, "push")
+    req.Header.Set(github.SHA256SignatureHeader, fmt.Sprintf("sha256=%x", mac.Sum(nil)))
+    w := httptest.NewRecorder()
+    postGitHubWebhook(w, req)
+
+    resp := w.Result()
+    body, _ := io.ReadAll(resp.Body)
+
+    if resp.StatusCode != status {
+        t.Errorf("Status code (%d) expected to be %d", resp.StatusCode, status)
+        return
+    }
+
+    if expected == nil {
+        if cache.Len() != 5 {
+            t.Errorf("No cache entry expected to be invalidated")
+        }
+        return
+    }
+
+    raw := &WebhookResponse{}
+    if err := json.Unmarshal(body, &raw); err != nil {
+        t.Errorf("Response body (%s) expected to be marshalled into struct (%#v)", string(body), raw)
+        return
+    }
+
+    if fmt.Sprint(raw.Invalidated) != fmt.Sprint(expected) {
+        t.Errorf("Invalidated keys (%v) expected to be (%v)", raw.Invalidated, expected)
+    }
+
+    for _, key := range expected {
+        if _, err := cache.Get(key); err == nil {
+            t.Errorf("Cache entry (%v) expected to be invalidated", key)
+        }
+
+        if _, err := cache.Get("token:caller/" + key); err == nil {
+            t.Errorf("Cache entry (%v) expected to be invalidated for every caller", key)
+        }
+    }
+}
+
+// --- HELPERS ---
+
+func testPushPayload(repo string, ref string, commit *github.HeadCommit) []byte {
+    b, _ := json.Marshal(&github.PushEvent{
+        Ref: github.String(ref),
+        Repo: &github.PushEventRepository{FullName: github.String(repo), DefaultBranch: github.String("main")},
+        Commits: []*github.HeadCommit{commit},
+    })
+
+    return b
+}
+
+// --- STRUCTS ---
+
+type webhookTestCase struct {
+    testName    string
+    secret      string
+    repo        string
+    ref         string
+    // size pads the payload, when it is larger.
+    size        int
+    commit      *github.HeadCommit
+    status      int
+    expected    []string
+}